@@ -0,0 +1,254 @@
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Kind says what went wrong in terms the client can act on. Each kind has one HTTP status.
+type Kind string
+
+const (
+	KindValidation      Kind = "validation"
+	KindUnauthorized    Kind = "unauthorized"
+	KindPaymentRequired Kind = "payment_required"
+	KindForbidden       Kind = "forbidden"
+	KindNotFound        Kind = "not_found"
+	KindConflict        Kind = "conflict"
+	KindUnprocessable   Kind = "unprocessable"
+	KindUpstream        Kind = "upstream" // a service we depend on, like the payment provider, failed
+	KindInternal        Kind = "internal"
+)
+
+var statuses = map[Kind]int{
+	KindValidation:      http.StatusBadRequest,
+	KindUnauthorized:    http.StatusUnauthorized,
+	KindPaymentRequired: http.StatusPaymentRequired,
+	KindForbidden:       http.StatusForbidden,
+	KindNotFound:        http.StatusNotFound,
+	KindConflict:        http.StatusConflict,
+	KindUnprocessable:   http.StatusUnprocessableEntity,
+	KindUpstream:        http.StatusBadGateway,
+	KindInternal:        http.StatusInternalServerError,
+}
+
+// FieldError explains what is wrong with one field of the request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"` // the failed rule, e.g. "required"
+	Message string `json:"message"`
+}
+
+// Error is an error meant for the client. Code is stable and machine-readable,
+// Message is for people and may change.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	Extra   map[string]interface{} // more members for the body, e.g. the booking a payment failed for
+	cause   error
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.cause)
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error { return e.cause }
+
+// Status is the HTTP status the error is sent with
+func (e *Error) Status() int {
+	if status, ok := statuses[e.Kind]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Wrap records the underlying error, which is logged but never sent to the client
+func (e *Error) Wrap(cause error) *Error {
+	e.cause = cause
+	return e
+}
+
+// With adds a member to the response body
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Extra == nil {
+		e.Extra = make(map[string]interface{})
+	}
+	e.Extra[key] = value
+	return e
+}
+
+// New builds an error of any kind
+func New(kind Kind, code string, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func Validation(code string, message string, fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Code: code, Message: message, Fields: fields}
+}
+
+func Unauthorized(code string, message string) *Error {
+	return New(KindUnauthorized, code, message)
+}
+
+func Forbidden(code string, message string) *Error {
+	return New(KindForbidden, code, message)
+}
+
+func NotFound(code string, message string) *Error {
+	return New(KindNotFound, code, message)
+}
+
+func Conflict(code string, message string) *Error {
+	return New(KindConflict, code, message)
+}
+
+// Internal hides cause from the client behind message
+func Internal(cause error, message string) *Error {
+	return New(KindInternal, "internal", message).Wrap(cause)
+}
+
+// As returns err as an *Error, treating anything else as internal
+func As(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return Internal(err, "Internal server error")
+}
+
+// FromBinding turns an error from gin's ShouldBind* into a validation error
+// with one entry per offending field
+func FromBinding(err error) *Error {
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) {
+		fields := make([]FieldError, 0, len(invalid))
+		for _, fe := range invalid {
+			fields = append(fields, FieldError{Field: fieldPath(fe), Code: fe.Tag(), Message: fieldMessage(fe)})
+		}
+		return Validation("invalid_request", "Invalid request data", fields...)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return Validation("invalid_request", "Invalid request data", FieldError{
+			Field:   typeErr.Field,
+			Code:    "type",
+			Message: "must be a " + typeErr.Type.String(),
+		})
+	}
+
+	return Validation("invalid_body", "Request body is not valid JSON: "+err.Error())
+}
+
+// fieldPath drops the struct name from the field's namespace, so
+// "Request.seats[0]" becomes "seats[0]"
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fe.Field()
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return "must be at least " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of " + fe.Param()
+	case "email":
+		return "must be an email address"
+	case "url":
+		return "must be a URL"
+	case "datetime":
+		return "must be formatted like " + fe.Param()
+	case "timezone":
+		return "must be an IANA time zone"
+	default:
+		return "failed the " + fe.Tag() + " check"
+	}
+}
+
+// Field errors name fields the way clients send them, by their JSON names
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// Write sends err to the client and stops the handler chain. Clients that
+// accept application/problem+json get an RFC 7807 problem document, others
+// get {"error": message, "code": code, "details": [...]}.
+func Write(c *gin.Context, err error) {
+	appErr := As(err)
+	_ = c.Error(err)
+
+	if appErr.Kind == KindInternal || appErr.Kind == KindUpstream {
+		log.Printf("❌ %s %s: %v", c.Request.Method, c.Request.URL.Path, appErr)
+	}
+
+	status := appErr.Status()
+	body := gin.H{}
+	for key, value := range appErr.Extra {
+		body[key] = value
+	}
+
+	if strings.Contains(c.GetHeader("Accept"), "application/problem+json") {
+		body["type"] = "/errors/" + appErr.Code
+		body["title"] = http.StatusText(status)
+		body["status"] = status
+		body["detail"] = appErr.Message
+		body["code"] = appErr.Code
+		if len(appErr.Fields) > 0 {
+			body["errors"] = appErr.Fields
+		}
+		c.Render(status, problemJSON{body})
+		c.Abort()
+		return
+	}
+
+	body["error"] = appErr.Message
+	body["code"] = appErr.Code
+	if len(appErr.Fields) > 0 {
+		body["details"] = appErr.Fields
+	}
+	c.AbortWithStatusJSON(status, body)
+}
+
+// problemJSON renders JSON with the application/problem+json content type
+type problemJSON struct {
+	data interface{}
+}
+
+func (r problemJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.data)
+}
+
+func (r problemJSON) WriteContentType(w http.ResponseWriter) {
+	w.Header()["Content-Type"] = []string{"application/problem+json; charset=utf-8"}
+}
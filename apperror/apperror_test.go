@@ -0,0 +1,67 @@
+package apperror
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test FromBinding reports each invalid field by its JSON name
+func TestFromBinding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var request struct {
+		ShowID uint     `json:"show_id" binding:"required"`
+		Seats  []string `json:"seats" binding:"required,min=1"`
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"seats":[]}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	appErr := FromBinding(c.ShouldBindJSON(&request))
+	assert.Equal(t, http.StatusBadRequest, appErr.Status())
+	assert.Equal(t, []FieldError{
+		{Field: "show_id", Code: "required", Message: "is required"},
+		{Field: "seats", Code: "min", Message: "must be at least 1"},
+	}, appErr.Fields)
+
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"show_id":"one"}`))
+	appErr = FromBinding(c.ShouldBindJSON(&request))
+	assert.Equal(t, "show_id", appErr.Fields[0].Field)
+}
+
+// Test Write renders plain JSON by default and RFC 7807 problems on request
+func TestWrite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) {
+		Write(c, NotFound("movie_not_found", "Movie not found"))
+	})
+	router.GET("/boom", func(c *gin.Context) {
+		Write(c, assert.AnError)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"Movie not found","code":"movie_not_found"}`, w.Body.String())
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/problem+json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "application/problem+json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"/errors/movie_not_found","title":"Not Found","status":404,"detail":"Movie not found","code":"movie_not_found"}`, w.Body.String())
+
+	// Causes of unexpected errors are not sent to the client
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/boom", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), assert.AnError.Error())
+}
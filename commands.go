@@ -0,0 +1,131 @@
+package main
+
+import (
+	"ETE3/config"
+	"ETE3/db"
+	"ETE3/migrations"
+	"ETE3/repair"
+	"ETE3/repository"
+	"ETE3/seed"
+	"ETE3/service"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// runMigrate handles "migrate up", "migrate down [steps]" and "migrate status"
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command, expected up, down or status")
+	}
+
+	switch args[0] {
+	case "up":
+		ran, err := migrations.Up(db.DB)
+		for _, migration := range ran {
+			log.Printf("✅ Applied migration %d %s", migration.Version, migration.Name)
+		}
+		if err == nil && len(ran) == 0 {
+			log.Println("Schema is up to date")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("steps must be a positive number, got %q", args[1])
+			}
+			steps = n
+		}
+
+		reverted, err := migrations.Down(db.DB, steps)
+		for _, migration := range reverted {
+			log.Printf("✅ Reverted migration %d %s", migration.Version, migration.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := migrations.List(db.DB)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.AppliedAt == nil {
+				fmt.Printf("%4d  %-40s pending\n", status.Version, status.Name)
+			} else {
+				fmt.Printf("%4d  %-40s applied %s\n", status.Version, status.Name, status.AppliedAt.Format("2006-01-02 15:04:05"))
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+}
+
+// runSeed loads the fixture file given as the only argument, or the bundled
+// default fixture, and inserts whatever is not in the database yet. Fixture
+// prices without a currency are in the configured one, and shows keep the
+// screen busy for the configured buffers.
+func runSeed(args []string, cfg *config.Config) error {
+	var fixture *seed.Fixture
+	var err error
+
+	if len(args) > 0 {
+		fixture, err = seed.LoadFile(args[0])
+	} else {
+		fixture, err = seed.Default()
+	}
+	if err != nil {
+		return err
+	}
+
+	if fixture.Currency == "" {
+		fixture.Currency = cfg.Currency
+	}
+
+	scheduling := service.NewSchedulingService(repository.NewStore(db.DB), cfg.Currency, nil)
+	scheduling.AdBuffer = cfg.Scheduling.AdBuffer.Duration
+	scheduling.CleaningBuffer = cfg.Scheduling.CleaningBuffer.Duration
+	result, err := seed.Run(db.DB, fixture, time.Now(), scheduling)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ Seeded %d movies, %d shows and %d seats, skipped %d overlapping shows",
+		result.MoviesCreated, result.ShowsCreated, result.SeatsCreated, result.ShowsSkipped)
+	return nil
+}
+
+// runRepair handles "repair booking-seats", which rebuilds seat links that
+// older versions deleted
+func runRepair(args []string) error {
+	if len(args) == 0 || args[0] != "booking-seats" {
+		return fmt.Errorf("expected repair booking-seats")
+	}
+
+	// Repairs work on the current schema
+	statuses, err := migrations.List(db.DB)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			return fmt.Errorf("migration %d %s is pending, run migrate up first", status.Version, status.Name)
+		}
+	}
+
+	result, err := repair.BookingSeats(db.DB)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ Restored %d booking seat links and reactivated %d", result.LinksCreated, result.LinksActivated)
+	if len(result.Orphaned) > 0 {
+		log.Printf("⚠️ %d booked seats belong to no live booking and were left as they are: %v", len(result.Orphaned), result.Orphaned)
+	}
+	return nil
+}
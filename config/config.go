@@ -0,0 +1,316 @@
+package config
+
+import (
+	"ETE3/money"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds everything the server needs to start
+type Config struct {
+	Port               string           `yaml:"port" toml:"port"`
+	JWTSecret          string           `yaml:"jwt_secret" toml:"jwt_secret"`
+	Database           DatabaseConfig   `yaml:"database" toml:"database"`
+	Admin              AdminConfig      `yaml:"admin" toml:"admin"`
+	HoldTTL            Duration         `yaml:"hold_ttl" toml:"hold_ttl"`
+	CancellationCutoff Duration         `yaml:"cancellation_cutoff" toml:"cancellation_cutoff"`
+	SeedOnStart        bool             `yaml:"seed_on_start" toml:"seed_on_start"` // load the default fixture when the server starts
+	Currency           string           `yaml:"currency" toml:"currency"`           // ISO 4217 code for prices given without one
+	TaxRateBPS         int64            `yaml:"tax_rate_bps" toml:"tax_rate_bps"`   // tax on each seat in basis points, 1800 is 18%
+	Payment            PaymentConfig    `yaml:"payment" toml:"payment"`
+	Refund             RefundConfig     `yaml:"refund" toml:"refund"`
+	Scheduling         SchedulingConfig `yaml:"scheduling" toml:"scheduling"`
+	IdempotencyTTL     Duration         `yaml:"idempotency_ttl" toml:"idempotency_ttl"` // how long responses to Idempotency-Key requests are replayed
+}
+
+// DatabaseConfig describes how to reach the database
+type DatabaseConfig struct {
+	Driver   string `yaml:"driver" toml:"driver"` // mysql, postgres, sqlite, sqlite-memory
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+	Host     string `yaml:"host" toml:"host"`
+	Port     string `yaml:"port" toml:"port"` // postgres only, the mysql host may include it
+	Name     string `yaml:"name" toml:"name"`
+	SSLMode  string `yaml:"ssl_mode" toml:"ssl_mode"` // postgres only
+	Path     string `yaml:"path" toml:"path"`         // sqlite only
+}
+
+// AdminConfig holds the credentials of the admin created at startup, if any
+type AdminConfig struct {
+	Email    string `yaml:"email" toml:"email"`
+	Password string `yaml:"password" toml:"password"`
+}
+
+// PaymentConfig selects the payment provider and how long a booking waits to be paid
+type PaymentConfig struct {
+	Provider      string   `yaml:"provider" toml:"provider"` // only "fake" for now
+	WebhookSecret string   `yaml:"webhook_secret" toml:"webhook_secret"`
+	TTL           Duration `yaml:"ttl" toml:"ttl"`
+}
+
+// RefundConfig is the refund policy for cancelled bookings
+type RefundConfig struct {
+	FullBefore Duration `yaml:"full_before" toml:"full_before"` // full refund when cancelling at least this long before the show
+	PartialBPS int64    `yaml:"partial_bps" toml:"partial_bps"` // share refunded after that, in basis points
+}
+
+// SchedulingConfig is how long a screen stays busy around each movie
+type SchedulingConfig struct {
+	AdBuffer       Duration `yaml:"ad_buffer" toml:"ad_buffer"`             // ads and trailers before the movie starts
+	CleaningBuffer Duration `yaml:"cleaning_buffer" toml:"cleaning_buffer"` // cleaning the screen after the movie ends
+}
+
+// Duration is a time.Duration that reads from strings like "10m" in config files
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// Default returns the configuration used when nothing overrides it
+func Default() *Config {
+	return &Config{
+		Port: "5000",
+		Database: DatabaseConfig{
+			Driver:  "mysql",
+			User:    "root",
+			Host:    "127.0.0.1",
+			Name:    "go_ete",
+			SSLMode: "disable",
+			Path:    "go_ete.db",
+		},
+		Currency:           "USD",
+		HoldTTL:            Duration{10 * time.Minute},
+		CancellationCutoff: Duration{2 * time.Hour},
+		IdempotencyTTL:     Duration{24 * time.Hour},
+		Payment: PaymentConfig{
+			Provider: "fake",
+			TTL:      Duration{15 * time.Minute},
+		},
+		Refund: RefundConfig{
+			FullBefore: Duration{24 * time.Hour},
+			PartialBPS: 5000,
+		},
+		Scheduling: SchedulingConfig{
+			AdBuffer:       Duration{15 * time.Minute},
+			CleaningBuffer: Duration{15 * time.Minute},
+		},
+	}
+}
+
+// Load builds the configuration from defaults, then the optional file named by
+// CONFIG_FILE (YAML or TOML), then environment variables and the .env file.
+func Load(envFile string) (*Config, error) {
+	// A missing .env is normal outside of local development
+	_ = godotenv.Load(envFile)
+
+	cfg := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadFile overlays values from a YAML or TOML file, picked by extension
+func (cfg *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config file type %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// loadEnv overlays values from environment variables that are set
+func (cfg *Config) loadEnv() error {
+	stringFields := map[string]*string{
+		"PORT":                   &cfg.Port,
+		"JWT_SECRET":             &cfg.JWTSecret,
+		"CURRENCY":               &cfg.Currency,
+		"DB_DRIVER":              &cfg.Database.Driver,
+		"DB_USER":                &cfg.Database.User,
+		"DB_PASSWORD":            &cfg.Database.Password,
+		"DB_HOST":                &cfg.Database.Host,
+		"DB_PORT":                &cfg.Database.Port,
+		"DB_NAME":                &cfg.Database.Name,
+		"DB_SSL_MODE":            &cfg.Database.SSLMode,
+		"DB_PATH":                &cfg.Database.Path,
+		"ADMIN_EMAIL":            &cfg.Admin.Email,
+		"ADMIN_PASSWORD":         &cfg.Admin.Password,
+		"PAYMENT_PROVIDER":       &cfg.Payment.Provider,
+		"PAYMENT_WEBHOOK_SECRET": &cfg.Payment.WebhookSecret,
+	}
+	for key, field := range stringFields {
+		if value, ok := os.LookupEnv(key); ok {
+			*field = value
+		}
+	}
+
+	boolFields := map[string]*bool{
+		"SEED_ON_START": &cfg.SeedOnStart,
+	}
+	for key, field := range boolFields {
+		if value, ok := os.LookupEnv(key); ok {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			*field = parsed
+		}
+	}
+
+	intFields := map[string]*int64{
+		"TAX_RATE_BPS":       &cfg.TaxRateBPS,
+		"REFUND_PARTIAL_BPS": &cfg.Refund.PartialBPS,
+	}
+	for key, field := range intFields {
+		if value, ok := os.LookupEnv(key); ok {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			*field = parsed
+		}
+	}
+
+	durationFields := map[string]*Duration{
+		"HOLD_TTL":             &cfg.HoldTTL,
+		"CANCELLATION_CUTOFF":  &cfg.CancellationCutoff,
+		"PAYMENT_TTL":          &cfg.Payment.TTL,
+		"REFUND_FULL_BEFORE":   &cfg.Refund.FullBefore,
+		"IDEMPOTENCY_TTL":      &cfg.IdempotencyTTL,
+		"SHOW_AD_BUFFER":       &cfg.Scheduling.AdBuffer,
+		"SHOW_CLEANING_BUFFER": &cfg.Scheduling.CleaningBuffer,
+	}
+	for key, field := range durationFields {
+		if value, ok := os.LookupEnv(key); ok {
+			if err := field.UnmarshalText([]byte(value)); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate reports every missing or invalid setting at once
+func (cfg *Config) Validate() error {
+	var problems []string
+
+	if cfg.Port == "" {
+		problems = append(problems, "port is required")
+	}
+	if cfg.JWTSecret == "" {
+		problems = append(problems, "jwt_secret (JWT_SECRET) is required")
+	}
+	switch cfg.Database.Driver {
+	case "mysql", "postgres":
+		if cfg.Database.User == "" {
+			problems = append(problems, "database user (DB_USER) is required")
+		}
+		if cfg.Database.Host == "" {
+			problems = append(problems, "database host (DB_HOST) is required")
+		}
+		if cfg.Database.Name == "" {
+			problems = append(problems, "database name (DB_NAME) is required")
+		}
+	case "sqlite":
+		if cfg.Database.Path == "" {
+			problems = append(problems, "database path (DB_PATH) is required for sqlite")
+		}
+	case "sqlite-memory":
+	default:
+		problems = append(problems, fmt.Sprintf("unknown database driver %q", cfg.Database.Driver))
+	}
+	if (cfg.Admin.Email == "") != (cfg.Admin.Password == "") {
+		problems = append(problems, "admin email and password must be set together")
+	}
+	if !money.ValidCurrency(cfg.Currency) {
+		problems = append(problems, fmt.Sprintf("currency %q is not an ISO 4217 code", cfg.Currency))
+	}
+	if cfg.TaxRateBPS < 0 || cfg.TaxRateBPS > 10000 {
+		problems = append(problems, "tax_rate_bps must be between 0 and 10000")
+	}
+	if cfg.HoldTTL.Duration <= 0 {
+		problems = append(problems, "hold_ttl must be positive")
+	}
+	if cfg.CancellationCutoff.Duration < 0 {
+		problems = append(problems, "cancellation_cutoff cannot be negative")
+	}
+	switch cfg.Payment.Provider {
+	case "fake":
+		if cfg.Payment.WebhookSecret == "" {
+			problems = append(problems, "payment webhook_secret (PAYMENT_WEBHOOK_SECRET) is required")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown payment provider %q", cfg.Payment.Provider))
+	}
+	if cfg.Payment.TTL.Duration <= 0 {
+		problems = append(problems, "payment ttl must be positive")
+	}
+	if cfg.IdempotencyTTL.Duration <= 0 {
+		problems = append(problems, "idempotency_ttl must be positive")
+	}
+	if cfg.Refund.FullBefore.Duration < 0 {
+		problems = append(problems, "refund full_before cannot be negative")
+	}
+	if cfg.Refund.PartialBPS < 0 || cfg.Refund.PartialBPS > 10000 {
+		problems = append(problems, "refund partial_bps must be between 0 and 10000")
+	}
+	if cfg.Scheduling.AdBuffer.Duration < 0 {
+		problems = append(problems, "scheduling ad_buffer cannot be negative")
+	}
+	if cfg.Scheduling.CleaningBuffer.Duration < 0 {
+		problems = append(problems, "scheduling cleaning_buffer cannot be negative")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Addr is the address the HTTP server listens on
+func (cfg *Config) Addr() string {
+	return ":" + cfg.Port
+}
@@ -1,29 +1,98 @@
 package db
 
 import (
+	"ETE3/config"
 	"fmt"
 	"log"
-	"os"
 
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
-func InitDB() {
+// Supported database drivers
+const (
+	DriverMySQL        = "mysql"
+	DriverPostgres     = "postgres"
+	DriverSQLite       = "sqlite"        // SQLite file at DatabaseConfig.Path
+	DriverSQLiteMemory = "sqlite-memory" // throwaway SQLite database, gone when the process exits
+)
+
+// dialectorFor picks the gorm dialector matching the configured driver
+func dialectorFor(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case DriverMySQL, "":
+		dataSourceName := fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8&parseTime=True&loc=Local",
+			cfg.User,
+			cfg.Password,
+			cfg.Host,
+			cfg.Name)
+		return mysql.Open(dataSourceName), nil
+	case DriverPostgres:
+		dataSourceName := fmt.Sprintf("host=%s user=%s password=%s dbname=%s sslmode=%s",
+			cfg.Host,
+			cfg.User,
+			cfg.Password,
+			cfg.Name,
+			cfg.SSLMode)
+		if cfg.Port != "" {
+			dataSourceName += " port=" + cfg.Port
+		}
+		return postgres.Open(dataSourceName), nil
+	case DriverSQLite:
+		return sqlite.Open(cfg.Path), nil
+	case DriverSQLiteMemory:
+		return sqlite.Open(":memory:"), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+}
+
+// Open connects to the database described by cfg without touching the global DB
+func Open(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	dialector, err := dialectorFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Translated errors let callers spot unique violations the same way on every driver
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer, and every connection to :memory: would
+	// see its own empty database, so keep the pool to one connection
+	if cfg.Driver == DriverSQLite || cfg.Driver == DriverSQLiteMemory {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	return db, nil
+}
+
+func InitDB(cfg config.DatabaseConfig) {
 	log.Default().Println("Initializing the connection to database.")
-	dataSourceName := fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8&parseTime=True&loc=Local",
-		"root",
-		"Romit@744812",
-		"127.0.0.1",
-		"go_ete")
 
-	db, err := gorm.Open(mysql.Open(dataSourceName))
+	db, err := Open(cfg)
 	if err != nil {
 		log.Fatalln("Database Initialization failed. ", err)
 	}
-	log.Println("Connected to database. Host: ", os.Getenv("DB_HOST"), ". Database:", os.Getenv("DB_NAME"))
+
+	switch cfg.Driver {
+	case DriverSQLite:
+		log.Println("Connected to database. Driver: ", cfg.Driver, ". Path:", cfg.Path)
+	case DriverSQLiteMemory:
+		log.Println("Connected to database. Driver: ", cfg.Driver)
+	default:
+		log.Println("Connected to database. Host: ", cfg.Host, ". Database:", cfg.Name)
+	}
 	DB = db
 }
 
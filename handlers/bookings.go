@@ -3,23 +3,19 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
-	"ETE3/db"
-	"ETE3/models"
+	"ETE3/apperror"
+	"ETE3/service"
 
 	"github.com/gin-gonic/gin"
 )
 
 // BookSeats handles the booking of multiple seats for a show
-func BookSeats(c *gin.Context) {
-	// Use a fixed userID for testing
+func (h *Handlers) BookSeats(c *gin.Context) {
 	userID, _ := c.MustGet("id").(uint)
 
-	if err := db.DB.Exec("DELETE FROM booking_seats").Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset booking seats table"})
-		return
-	}
-
 	var bookingRequest struct {
 		ShowID uint     `json:"show_id" binding:"required"`
 		Seats  []string `json:"seats" binding:"required,min=1"`
@@ -27,127 +23,171 @@ func BookSeats(c *gin.Context) {
 
 	// Bind the JSON request data to the struct
 	if err := c.ShouldBindJSON(&bookingRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+		apperror.Write(c, apperror.FromBinding(err))
 		return
 	}
 
-	// Begin a transaction
-	tx := db.DB.Begin()
-	if tx.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+	booked, err := h.Bookings.Book(c.Request.Context(), userID, bookingRequest.ShowID, bookingRequest.Seats)
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
 
-	// Fetch the show details using ShowID
-	var show models.Show
-	if err := tx.First(&show, bookingRequest.ShowID).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{"error": "Show not found"})
-		return
-	}
+	// Return success response
+	booking := booked.Booking
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Booking created, awaiting payment",
+		"booking_id":  booking.ID,
+		"show_id":     booked.Show.ID,
+		"seats":       bookingRequest.Seats,
+		"total_price": booking.TotalPrice,
+		"line_items":  booking.LineItems,
+		"status":      booking.Status,
+		"payment": gin.H{
+			"intent_id":     booked.Intent.ID,
+			"client_secret": booked.Intent.ClientSecret,
+			"expires_at":    booking.PaymentExpiresAt,
+		},
+	})
+}
+
+// CancelBooking cancels one of the caller's bookings and releases its seats
+func (h *Handlers) CancelBooking(c *gin.Context) {
+	userID, _ := c.MustGet("id").(uint)
 
-	// Get all seats for this show first, then filter in memory
-	var allShowSeats []models.Seat
-	if err := tx.Raw("SELECT * FROM seats WHERE show_id = ? AND deleted_at IS NULL",
-		bookingRequest.ShowID).Scan(&allShowSeats).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch seats"})
+	bookingID, err := idParam(c, "id")
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
-	// Create a map for easy lookup
-	seatMap := make(map[string]models.Seat)
-	for _, seat := range allShowSeats {
-		key := fmt.Sprintf("%s%d", seat.Row, seat.Number)
-		seatMap[key] = seat
+	var cancelRequest struct {
+		Reason string `json:"reason"`
 	}
 
-	// Validate each requested seat
-	var seatsToBook []models.Seat
-	for _, label := range bookingRequest.Seats {
-		seat, exists := seatMap[label]
-		if !exists {
-			tx.Rollback()
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": fmt.Sprintf("Seat %s not found", label),
-			})
+	// The body is optional, a cancellation without a reason is fine
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&cancelRequest); err != nil {
+			apperror.Write(c, apperror.FromBinding(err))
 			return
 		}
+	}
 
-		if seat.Status != models.Available {
-			tx.Rollback()
-			c.JSON(http.StatusConflict, gin.H{
-				"error": fmt.Sprintf("Seat %s is already booked", label),
-			})
-			return
-		}
+	// Staff can cancel on a customer's behalf
+	booking, refund, err := h.Bookings.Cancel(c.Request.Context(), userID, c.GetString("role"), bookingID, cancelRequest.Reason)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Booking cancelled",
+		"booking_id": booking.ID,
+		"status":     booking.Status,
+		"refund":     refund,
+	})
+}
 
-		seatsToBook = append(seatsToBook, seat)
+// Default and maximum page sizes for list endpoints
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pageParams reads the page and page_size query parameters
+func pageParams(c *gin.Context) (page int, pageSize int, err error) {
+	page, err = strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		return 0, 0, apperror.Validation("invalid_query", "Invalid query parameters", apperror.FieldError{
+			Field: "page", Code: "min", Message: "must be a positive number",
+		})
 	}
 
-	// Update each seat status directly in the database
-	for _, seat := range seatsToBook {
-		// Use raw SQL to update status
-		if err := tx.Exec("UPDATE seats SET status = ? WHERE id = ?",
-			models.Booked, seat.ID).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to update seat status",
-			})
-			return
-		}
+	pageSize, err = strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
+	if err != nil || pageSize < 1 || pageSize > maxPageSize {
+		return 0, 0, apperror.Validation("invalid_query", "Invalid query parameters", apperror.FieldError{
+			Field: "page_size", Code: "range", Message: fmt.Sprintf("must be between 1 and %d", maxPageSize),
+		})
 	}
 
-	// Create a new booking
-	booking := models.Booking{
-		UserID: uint(userID),
-		ShowID: bookingRequest.ShowID,
-		Status: "confirmed",
+	return page, pageSize, nil
+}
+
+// bookingSummary flattens a booking with its show and movie into a response object
+func bookingSummary(details service.BookingDetails) gin.H {
+	booking, show, movie := details.Booking, details.Show, details.Movie
+
+	seatLabels := make([]string, 0, len(booking.Seats))
+	for _, seat := range booking.Seats {
+		seatLabels = append(seatLabels, seat.Row+strconv.Itoa(seat.Number))
 	}
 
-	if err := tx.Create(&booking).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create booking",
-		})
-		return
+	return gin.H{
+		"booking_id":  booking.ID,
+		"show_id":     show.ID,
+		"movie_id":    movie.ID,
+		"movie_title": movie.Title,
+		"show_time":   show.Time.Format(time.RFC3339),
+		"seats":       seatLabels,
+		"price_paid":  booking.TotalPrice,
+		"status":      booking.Status,
+		"booked_at":   booking.CreatedAt.Format(time.RFC3339),
 	}
+}
 
-	// Add seat associations using raw SQL to avoid GORM issues
-	for _, seat := range seatsToBook {
-		if err := tx.Exec("INSERT INTO booking_seats (booking_id, seat_id) VALUES (?, ?)",
-			booking.ID, seat.ID).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to associate seats with booking",
-			})
-			return
-		}
+// GetMyBookings lists the caller's bookings, newest show first
+func (h *Handlers) GetMyBookings(c *gin.Context) {
+	userID, _ := c.MustGet("id").(uint)
+
+	page, pageSize, err := pageParams(c)
+	if err != nil {
+		apperror.Write(c, err)
+		return
 	}
 
-	// Commit the transaction
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to complete booking transaction",
-		})
+	// Optionally keep only upcoming or past shows
+	bookings, total, err := h.Bookings.ListForUser(userID, c.Query("when"), page, pageSize)
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
-	// Calculate total price
-	totalPrice := show.Price * float64(len(bookingRequest.Seats))
+	results := make([]gin.H, 0, len(bookings))
+	for _, booking := range bookings {
+		results = append(results, bookingSummary(booking))
+	}
 
-	// Return success response
 	c.JSON(http.StatusOK, gin.H{
-		"message":     "Booking confirmed",
-		"booking_id":  booking.ID,
-		"show_id":     show.ID,
-		"seats":       bookingRequest.Seats,
-		"total_price": totalPrice,
-		"status":      booking.Status,
+		"bookings":  results,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
 	})
 }
+
+// GetMyBooking returns a single booking, as long as it belongs to the caller
+func (h *Handlers) GetMyBooking(c *gin.Context) {
+	userID, _ := c.MustGet("id").(uint)
+
+	bookingID, err := idParam(c, "id")
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	details, err := h.Bookings.GetForUser(userID, bookingID)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	booking := details.Booking
+	summary := bookingSummary(details)
+	summary["line_items"] = booking.LineItems
+	summary["cancelled_at"] = booking.CancelledAt
+	summary["cancel_reason"] = booking.CancelReason
+	summary["refund_status"] = booking.RefundStatus
+	summary["refunds"] = booking.Refunds
+
+	c.JSON(http.StatusOK, summary)
+}
@@ -1,31 +1,90 @@
 package handlers
 
 import (
+	"ETE3/apperror"
+	"ETE3/config"
 	"ETE3/middleware"
+	"ETE3/models"
+	"ETE3/service"
 	"log"
+	"strconv"
 
 	cors "github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func SetupRouter() *gin.Engine {
+// Handlers turns HTTP requests into calls on the services and their results
+// into responses
+type Handlers struct {
+	Bookings   *service.BookingService
+	Scheduling *service.SchedulingService
+	Auth       *service.AuthService
+}
+
+// New returns Handlers backed by the given services
+func New(bookings *service.BookingService, scheduling *service.SchedulingService, auth *service.AuthService) *Handlers {
+	return &Handlers{Bookings: bookings, Scheduling: scheduling, Auth: auth}
+}
+
+// SetupRouter routes the API to h. database stores the responses of requests
+// sent with an Idempotency-Key.
+func SetupRouter(cfg *config.Config, h *Handlers, database *gorm.DB) *gin.Engine {
 	log.Println("Router Setup Started.")
 	r := gin.Default()
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
-	config.AllowHeaders = []string{"Authorization", "authorization", "Content-Type", "content-type"}
+	config.AllowHeaders = []string{"Authorization", "authorization", "Content-Type", "content-type", "Idempotency-Key"}
 	r.Use(cors.New(config))
-	tokenmiddleware := r.Group("/").Use(middleware.AuthMiddleware())
-
-	r.POST("/user/register", Register)
-	r.POST("/user/login", Login)
-	r.POST("/movie/add", AddMovie)
-	r.POST("/show/add", AddShowHandler)
-	r.GET("/movie/get", GetAllMovies)
-	r.GET("/show/get/:movie_id", GetShowsByMovie)
-	r.GET("/movie/get/:id", GetMovie)
-	tokenmiddleware.POST("/show/book", BookSeats)
-	r.GET("/show/seats/get/:show_id", GetAvailableSeatsHandler)
+	r.Use(middleware.Errors())
+	secret := []byte(cfg.JWTSecret)
+	// Authenticated routes accept an Idempotency-Key header so retries are safe
+	idempotency := middleware.Idempotency(database, cfg.IdempotencyTTL.Duration)
+	tokenmiddleware := r.Group("/").Use(middleware.AuthMiddleware(secret), idempotency)
+	staff := r.Group("/").Use(middleware.AuthMiddleware(secret), middleware.RequireRole(models.RoleStaff, models.RoleAdmin), idempotency)
+	admin := r.Group("/").Use(middleware.AuthMiddleware(secret), middleware.RequireRole(models.RoleAdmin), idempotency)
+
+	r.POST("/user/register", h.Register)
+	r.POST("/user/login", h.Login)
+	admin.POST("/user/role/:id", h.SetUserRole)
+	staff.POST("/movie/add", h.AddMovie)
+	staff.PUT("/movie/update/:id", h.UpdateMovie)
+	staff.DELETE("/movie/delete/:id", h.DeleteMovie)
+	staff.POST("/show/add", h.AddShowHandler)
+	staff.PUT("/show/update/:id", h.UpdateShow)
+	staff.POST("/show/cancel/:id", h.CancelShow)
+	staff.POST("/schedule/add", h.AddSchedule)
+	staff.GET("/schedule/get/:id", h.GetSchedule)
+	staff.PUT("/schedule/update/:id", h.UpdateSchedule)
+	staff.POST("/schedule/cancel/:id", h.CancelSchedule)
+	staff.POST("/theater/add", h.AddTheater)
+	staff.POST("/screen/add", h.AddScreen)
+	r.GET("/theater/get", h.GetAllTheaters)
+	r.GET("/theater/get/:id", h.GetTheater)
+	r.GET("/movie/get", h.GetAllMovies)
+	r.GET("/show/listings", h.GetListings)
+	r.GET("/show/get/:movie_id", h.GetShowsByMovie)
+	r.GET("/movie/get/:id", h.GetMovie)
+	tokenmiddleware.POST("/show/hold", h.HoldSeats)
+	tokenmiddleware.POST("/show/book", h.BookSeats)
+	tokenmiddleware.POST("/booking/pay/:id", h.ConfirmBookingPayment)
+	tokenmiddleware.POST("/booking/cancel/:id", h.CancelBooking)
+	r.POST("/payments/webhook", h.PaymentWebhook)
+	tokenmiddleware.GET("/booking/get", h.GetMyBookings)
+	tokenmiddleware.GET("/booking/get/:id", h.GetMyBooking)
+	r.GET("/show/seats/get/:show_id", h.GetAvailableSeatsHandler)
+	r.GET("/show/seatmap/get/:show_id", h.GetSeatMap)
 
 	return r
 }
+
+// idParam reads a numeric ID from the named path parameter
+func idParam(c *gin.Context, name string) (uint, error) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil {
+		return 0, apperror.Validation("invalid_id", "Invalid ID", apperror.FieldError{
+			Field: name, Code: "id", Message: "must be a positive number",
+		})
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,987 @@
+package handlers
+
+import (
+	"ETE3/config"
+	"ETE3/db"
+	"ETE3/middleware"
+	"ETE3/migrations"
+	"ETE3/models"
+	"ETE3/money"
+	"ETE3/payments"
+	"ETE3/refunds"
+	"ETE3/repository"
+	"ETE3/service"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var setTestMode sync.Once
+
+// testEnv is an in-memory SQLite database with the services and handlers on
+// top of it. Every test gets its own, so tests can run in parallel.
+type testEnv struct {
+	db       *gorm.DB
+	payments *payments.Fake
+	bookings *service.BookingService
+	handlers *Handlers
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	setTestMode.Do(func() { gin.SetMode(gin.TestMode) })
+
+	testDB, err := db.Open(config.DatabaseConfig{Driver: db.DriverSQLiteMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.Up(testDB); err != nil {
+		t.Fatal(err)
+	}
+
+	store := repository.NewStore(testDB)
+	fake := payments.NewFake("test-secret")
+	bookings := service.NewBookingService(store, fake, &refunds.Service{
+		Provider: fake,
+		Policy:   refunds.Policy{FullRefundBefore: 24 * time.Hour, PartialRefundBPS: 5000},
+	})
+
+	return &testEnv{
+		db:       testDB,
+		payments: fake,
+		bookings: bookings,
+		handlers: New(bookings, service.NewSchedulingService(store, "USD", bookings.Refunds), service.NewAuthService(store, []byte("test-secret"))),
+	}
+}
+
+// router serves the handlers at their SetupRouter paths without
+// authentication, every request is made by the given user
+func (e *testEnv) router(userID uint, extra ...gin.HandlerFunc) *gin.Engine {
+	h := e.handlers
+	r := gin.New()
+	r.Use(withUser(userID))
+	r.Use(extra...)
+	r.POST("/user/register", h.Register)
+	r.POST("/user/login", h.Login)
+	r.POST("/movie/add", h.AddMovie)
+	r.PUT("/movie/update/:id", h.UpdateMovie)
+	r.DELETE("/movie/delete/:id", h.DeleteMovie)
+	r.POST("/show/add", h.AddShowHandler)
+	r.PUT("/show/update/:id", h.UpdateShow)
+	r.POST("/show/cancel/:id", h.CancelShow)
+	r.POST("/schedule/add", h.AddSchedule)
+	r.GET("/schedule/get/:id", h.GetSchedule)
+	r.PUT("/schedule/update/:id", h.UpdateSchedule)
+	r.POST("/schedule/cancel/:id", h.CancelSchedule)
+	r.POST("/screen/add", h.AddScreen)
+	r.GET("/movie/get", h.GetAllMovies)
+	r.GET("/movie/get/:id", h.GetMovie)
+	r.GET("/show/listings", h.GetListings)
+	r.GET("/show/get/:movie_id", h.GetShowsByMovie)
+	r.GET("/show/seats/get/:show_id", h.GetAvailableSeatsHandler)
+	r.GET("/show/seatmap/get/:show_id", h.GetSeatMap)
+	r.POST("/show/hold", h.HoldSeats)
+	r.POST("/show/book", h.BookSeats)
+	r.POST("/booking/pay/:id", h.ConfirmBookingPayment)
+	r.POST("/booking/cancel/:id", h.CancelBooking)
+	r.POST("/payments/webhook", h.PaymentWebhook)
+	r.GET("/booking/get", h.GetMyBookings)
+	r.GET("/booking/get/:id", h.GetMyBooking)
+	return r
+}
+
+// Create a theater with one screen using the given layout
+func createScreen(testDB *gorm.DB, layout models.SeatLayout) models.Screen {
+	theater := models.Theater{Name: "Test Theater", City: "Test City"}
+	testDB.Create(&theater)
+	screen := models.Screen{TheaterID: theater.ID, Name: "Screen 1", Layout: layout}
+	testDB.Create(&screen)
+	return screen
+}
+
+// Stand-in for AuthMiddleware that logs every request in as the given user
+func withUser(userID uint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("id", userID)
+		c.Next()
+	}
+}
+
+// Send a JSON POST through the router and return the recorded response
+func postJSON(router *gin.Engine, path string, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodPost, path, bytes.NewBuffer([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Send a GET through the router and return the recorded response
+func get(router *gin.Engine, path string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Test AddMovie Handler
+func TestAddMovie(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	router := env.router(1)
+	w := postJSON(router, "/movie/add", `{"title":"Test Movie","duration":120}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Movie added successfully")
+
+	// Metadata is checked field by field
+	w = postJSON(router, "/movie/add", `{"title":"Bad Movie","duration":0,"status":"released",
+		"trailer_url":"not a url","cast":[{"character":"Nobody"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	for _, field := range []string{"duration", "status", "trailer_url", "cast[0].name"} {
+		assert.Contains(t, w.Body.String(), `"field":"`+field+`"`)
+	}
+
+	// Clients cannot pick the ID
+	w = postJSON(router, "/movie/add", `{"ID":42,"title":"Sneaky","duration":90}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"movie_id":2`)
+}
+
+// Test a movie's metadata can be updated and the movie soft-deleted
+func TestUpdateAndDeleteMovie(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	router := env.router(1)
+	send := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := postJSON(router, "/movie/add", `{"title":"Arrival","duration":116,"genres":["sci-fi"]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var movie models.Movie
+	env.db.First(&movie)
+	assert.Equal(t, models.MovieComingSoon, movie.Status)
+
+	w = send(http.MethodPut, "/movie/update/1", `{"title":"Arrival","duration":116,"genres":["sci-fi","drama"],
+		"language":"en","subtitles":["fr","de"],"certification":"PG-13","release_date":"2016-11-11T00:00:00Z",
+		"cast":[{"name":"Amy Adams","character":"Louise Banks"}],"crew":[{"name":"Denis Villeneuve","job":"Director"}],
+		"trailer_url":"https://example.com/arrival","status":"now_showing"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = get(router, "/movie/get/1")
+	assert.Contains(t, w.Body.String(), `"genres":["sci-fi","drama"]`)
+	assert.Contains(t, w.Body.String(), `"crew":[{"name":"Denis Villeneuve","job":"Director"}]`)
+	assert.Contains(t, w.Body.String(), `"status":"now_showing"`)
+
+	w = send(http.MethodPut, "/movie/update/9", `{"title":"Arrival","duration":116}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// A movie with shows still to come stays
+	env.db.Create(&models.Show{MovieID: 1, Time: time.Now().UTC().Add(time.Hour)})
+	w = send(http.MethodDelete, "/movie/delete/1", ``)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"movie_has_shows"`)
+
+	env.db.Model(&models.Show{}).Where("movie_id = ?", 1).Update("time", time.Now().UTC().Add(-time.Hour))
+	w = send(http.MethodDelete, "/movie/delete/1", ``)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = get(router, "/movie/get/1")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var deleted models.Movie
+	assert.NoError(t, env.db.Unscoped().First(&deleted, 1).Error)
+	assert.True(t, deleted.DeletedAt.Valid)
+}
+
+// Test AddShowHandler
+func TestAddShowHandler(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	createScreen(env.db, models.GridLayout("ABCDEFGHIJ", 15))
+	env.db.Create(&models.Movie{Title: "Test Movie", Duration: 120})
+	router := env.router(1)
+
+	w := postJSON(router, "/show/add", `{"movie_id":1,"screen_id":1,"time":"2099-01-01T18:00:00Z"}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Show created successfully")
+	assert.Contains(t, w.Body.String(), `"ends_at":"2099-01-01T20:00:00Z"`)
+
+	var seatCount int64
+	env.db.Model(&models.Seat{}).Where("show_id = ?", 1).Count(&seatCount)
+	assert.Equal(t, int64(150), seatCount)
+
+	// A show needs a movie, a screen and a time
+	w = postJSON(router, "/show/add", `{"movie_id":1,"screen_id":1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"time"`)
+
+	w = postJSON(router, "/show/add", `{"movie_id":1,"screen_id":1,"time":"2000-01-01T18:00:00Z"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"future"`)
+
+	w = postJSON(router, "/show/add", `{"movie_id":9,"screen_id":1,"time":"2099-01-02T18:00:00Z"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "movie_not_found")
+
+	// The screen is still busy at 19:00, the response says with what
+	w = postJSON(router, "/show/add", `{"movie_id":1,"screen_id":1,"time":"2099-01-01T19:00:00Z"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var body struct {
+		Code            string      `json:"code"`
+		ConflictingShow models.Show `json:"conflicting_show"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "show_conflict", body.Code)
+	assert.Equal(t, uint(1), body.ConflictingShow.ID)
+}
+
+// Test AddShowHandler generates seats from a layout with aisles and uneven rows
+func TestAddShowHandlerUsesScreenLayout(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	seat := func(number int) models.LayoutPosition {
+		return models.LayoutPosition{Kind: models.PositionSeat, Number: number}
+	}
+	aisle := models.LayoutPosition{Kind: models.PositionAisle}
+	createScreen(env.db, models.SeatLayout{Rows: []models.LayoutRow{
+		{Label: "A", Positions: []models.LayoutPosition{seat(1), aisle, seat(2)}},
+		{Label: "B", Positions: []models.LayoutPosition{seat(1), seat(2), aisle, seat(3), seat(4)}},
+	}})
+
+	env.db.Create(&models.Movie{Title: "Test Movie", Duration: 120})
+
+	router := env.router(1)
+	w := postJSON(router, "/show/add", `{"movie_id":1,"screen_id":1,"time":"2099-01-01T18:00:00Z"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var seats []models.Seat
+	env.db.Order("y, x").Find(&seats)
+	assert.Len(t, seats, 6)
+
+	// A2 sits right of the aisle
+	assert.Equal(t, "A", seats[1].Row)
+	assert.Equal(t, 2, seats[1].Number)
+	assert.Equal(t, 2, seats[1].X)
+	assert.Equal(t, 0, seats[1].Y)
+	assert.Equal(t, models.SeatStandard, seats[1].Type)
+
+	// A show needs an existing screen
+	w = postJSON(router, "/show/add", `{"movie_id":1,"screen_id":9,"time":"2099-01-02T18:00:00Z"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// Test a show can be moved, repriced and cancelled
+func TestUpdateAndCancelShow(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	createScreen(env.db, models.GridLayout("AB", 5))
+	env.db.Create(&models.Movie{Title: "Test Movie", Duration: 120})
+	router := env.router(1)
+	send := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := postJSON(router, "/show/add", `{"movie_id":1,"screen_id":1,"time":"2099-01-01T18:00:00Z"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = send(http.MethodPut, "/show/update/1", `{"time":"2099-01-01T20:00:00Z","price":{"amount":1500}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"time":"2099-01-01T20:00:00Z"`)
+	assert.Contains(t, w.Body.String(), `"amount":1500`)
+	assert.Contains(t, w.Body.String(), `"needs_attention":[]`)
+
+	w = send(http.MethodPut, "/show/update/1", `{"screen_id":9}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = postJSON(router, "/show/cancel/1", `{"reason":"Projector broken"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"cancel_reason":"Projector broken"`)
+
+	w = postJSON(router, "/show/cancel/1", ``)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"show_cancelled"`)
+
+	w = get(router, "/show/get/1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"shows":[]`)
+}
+
+// Test schedules can be previewed, report conflicts and generate their shows
+func TestScheduleHandlers(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	createScreen(env.db, models.GridLayout("AB", 5))
+	env.db.Create(&models.Movie{Title: "Test Movie", Duration: 120})
+	router := env.router(1)
+
+	// Every Monday and Friday evening of the first two weeks of 2099
+	schedule := `{"movie_id":1,"screen_id":1,"weekdays":["mon","fri"],"times":["18:00"],
+		"start_date":"2099-01-05","end_date":"2099-01-18","price":{"amount":1000}}`
+
+	w := postJSON(router, "/schedule/add?dry_run=true", schedule)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var preview struct {
+		DryRun      bool                         `json:"dry_run"`
+		Occurrences []service.ScheduleOccurrence `json:"occurrences"`
+		Conflicts   int                          `json:"conflicts"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &preview))
+	assert.True(t, preview.DryRun)
+	assert.Len(t, preview.Occurrences, 4)
+	assert.Equal(t, 0, preview.Conflicts)
+
+	var shows int64
+	env.db.Model(&models.Show{}).Count(&shows)
+	assert.Equal(t, int64(0), shows)
+
+	w = postJSON(router, "/schedule/add?dry_run=maybe", schedule)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = postJSON(router, "/schedule/add", `{"movie_id":1,"screen_id":1,"weekdays":["someday"],"times":["6pm"],
+		"start_date":"2099-01-05","end_date":"2099-01-18"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"weekdays[0]"`)
+	assert.Contains(t, w.Body.String(), `"field":"times[0]"`)
+
+	w = postJSON(router, "/schedule/add", schedule)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Schedule created successfully")
+	env.db.Model(&models.Show{}).Count(&shows)
+	assert.Equal(t, int64(4), shows)
+
+	// The same schedule again would put every show on top of one already there
+	w = postJSON(router, "/schedule/add", schedule)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var conflict struct {
+		Code      string                       `json:"code"`
+		Conflicts []service.ScheduleOccurrence `json:"conflicts"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &conflict))
+	assert.Equal(t, "schedule_conflict", conflict.Code)
+	assert.Len(t, conflict.Conflicts, 4)
+
+	w = get(router, "/schedule/get/1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"upcoming_shows"`)
+
+	w = postJSON(router, "/schedule/cancel/1", ``)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"shows_cancelled":4`)
+
+	w = get(router, "/schedule/get/1")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// Test AddScreen rejects layouts with duplicate seats
+func TestAddScreenValidatesLayout(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	env.db.Create(&models.Theater{Name: "Test Theater", City: "Test City"})
+
+	router := env.router(1)
+	w := postJSON(router, "/screen/add", `{"theater_id":1,"name":"Screen 1","layout":{"rows":[
+		{"label":"A","positions":[{"kind":"seat","number":1},{"kind":"seat","number":1}]}]}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "A1 appears twice")
+
+	w = postJSON(router, "/screen/add", `{"theater_id":1,"name":"Screen 1","layout":{"rows":[
+		{"label":"A","positions":[{"kind":"seat","number":1},{"kind":"gap"},{"kind":"seat","number":2}]}]}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var screen models.Screen
+	env.db.First(&screen)
+	assert.Len(t, screen.Layout.Rows[0].Positions, 3)
+}
+
+// Test GetAllMovies
+func TestGetAllMovies(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	// Insert test data
+	env.db.Create(&models.Movie{Title: "Test Movie 1"})
+	env.db.Create(&models.Movie{Title: "Test Movie 2"})
+
+	w := get(env.router(1), "/movie/get")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Test Movie 1")
+	assert.Contains(t, w.Body.String(), "Test Movie 2")
+}
+
+// Test GetMovie reports a missing movie as not found and a malformed ID as invalid
+func TestGetMovieNotFound(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	router := env.router(1)
+	w := get(router, "/movie/get/42")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"movie_not_found"`)
+
+	w = get(router, "/movie/get/forty-two")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"invalid_id"`)
+}
+
+// Test Register rejects duplicate emails and Login checks the password
+func TestRegisterAndLogin(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	router := env.router(0)
+
+	w := postJSON(router, "/user/register", `{"name":"Ana","email":"ana@example.com"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"password"`)
+
+	w = postJSON(router, "/user/register", `{"name":"Ana","email":"ana@example.com","password":"secret"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = postJSON(router, "/user/register", `{"name":"Ana","email":"ana@example.com","password":"secret"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"email_taken"`)
+
+	w = postJSON(router, "/user/login", `{"email":"ana@example.com","password":"wrong"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = postJSON(router, "/user/login", `{"email":"nobody@example.com","password":"secret"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = postJSON(router, "/user/login", `{"email":"ana@example.com","password":"secret"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"token"`)
+}
+
+// Test GetShowsByMovie
+func TestGetShowsByMovie(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	// Insert test data
+	env.db.Create(&models.Movie{Title: "Test Movie"})
+	env.db.Create(&models.Movie{Title: "No Shows"})
+	env.db.Create(&models.Show{MovieID: 1, Time: time.Date(2000, time.January, 1, 18, 0, 0, 0, time.UTC)})
+	env.db.Create(&models.Show{MovieID: 1, Time: time.Date(2099, time.January, 1, 18, 0, 0, 0, time.UTC)})
+	router := env.router(1)
+
+	// Only upcoming shows are listed
+	w := get(router, "/show/get/1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"time":"2099-01-01T18:00:00Z"`)
+	assert.NotContains(t, w.Body.String(), `2000-01-01`)
+
+	w = get(router, "/show/get/2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"shows":[]`)
+
+	w = get(router, "/show/get/9")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"movie_not_found"`)
+}
+
+// Test GetListings groups upcoming shows and rejects bad filters
+func TestGetListings(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	createScreen(env.db, models.GridLayout("AB", 5))
+	env.db.Create(&models.Movie{Title: "Test Movie", Duration: 120, Language: "English"})
+	router := env.router(1)
+
+	w := postJSON(router, "/show/add", `{"movie_id":1,"screen_id":1,"time":"2099-01-01T18:00:00Z","format":"IMAX","price":{"amount":1500}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = postJSON(router, "/show/add", `{"movie_id":1,"screen_id":1,"time":"2099-01-01T11:00:00+02:00"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = get(router, "/show/listings?from=2099-01-01&city=Test%20City&language=english&format=imax&time_of_day=evening,night&max_price=20")
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `"title":"Test Movie"`)
+	assert.Contains(t, body, `"name":"Test Theater"`)
+	assert.Contains(t, body, `"format":"IMAX"`)
+	assert.Contains(t, body, `"seats_available":10`)
+	assert.NotContains(t, body, `"time":"2099-01-01T09:00:00Z"`)
+
+	w = get(router, "/show/listings?from=2099-01-01&time_of_day=morning")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"time":"2099-01-01T09:00:00Z"`)
+
+	w = get(router, "/show/listings?from=2099-01-02")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"movies":[]`)
+
+	for _, query := range []string{"theater_id=x", "from=2099-01-01&to=2099-02-01", "format=4D", "time_of_day=brunch"} {
+		w = get(router, "/show/listings?"+query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_query"`, query)
+	}
+}
+
+// Test GetAvailableSeatsHandler
+func TestGetAvailableSeatsHandler(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	// Insert test data
+	env.db.Create(&models.Show{MovieID: 1})
+	env.db.Create(&models.Seat{ShowID: 1, Row: "A", Number: 1, Status: models.Available})
+	env.db.Create(&models.Seat{ShowID: 1, Row: "A", Number: 2, Status: models.Available})
+
+	w := get(env.router(1), "/show/seats/get/1")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total_available":2`)
+}
+
+// Test GetSeatMap returns the whole layout and answers unchanged polls with 304
+func TestGetSeatMap(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	createScreen(env.db, models.GridLayout("AB", 3))
+	env.db.Create(&models.Movie{Title: "Test Movie", Duration: 120})
+	router := env.router(1)
+	w := postJSON(router, "/show/add", `{"movie_id":1,"screen_id":1,"time":"2099-01-01T18:00:00Z","price":{"amount":1000}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	poll := func(etag string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/show/seatmap/get/1", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w = poll("")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"seat":"B3"`)
+	assert.Contains(t, w.Body.String(), `"counts":{"available":6,"held":0,"booked":0,"blocked":0}`)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	w = poll(etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	w = poll(`"other", W/` + etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	// Holding a seat changes the map
+	w = postJSON(router, "/show/hold", `{"show_id":1,"seats":["A1"]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = poll(etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"held":1`)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	// A seat the show does not sell is blocked, still drawn as a seat
+	env.db.Where("show_id = ? AND row = ? AND number = ?", 1, "B", 3).Delete(&models.Seat{})
+	w = poll("")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `{"kind":"seat","x":2,"seat":"B3","number":3,"category":"standard","state":"blocked"}`)
+	assert.Contains(t, w.Body.String(), `"blocked":1`)
+
+	w = get(router, "/show/seatmap/get/9")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// Test HoldSeats and BookSeats: only the holder can book a held seat
+func TestHoldThenBookSeats(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	env.db.Create(&models.Show{MovieID: 1, Price: money.New(1000, "USD")})
+	env.db.Create(&models.Seat{ShowID: 1, Row: "A", Number: 1, Status: models.Available})
+
+	owner := env.router(1)
+	other := env.router(2)
+
+	// Booking without a hold is rejected
+	w := postJSON(owner, "/show/book", `{"show_id":1,"seats":["A1"]}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = postJSON(owner, "/show/hold", `{"show_id":1,"seats":["A1"]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Someone else can neither hold nor book the seat
+	w = postJSON(other, "/show/hold", `{"show_id":1,"seats":["A1"]}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = postJSON(other, "/show/book", `{"show_id":1,"seats":["A1"]}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = postJSON(owner, "/show/book", `{"show_id":1,"seats":["A1"]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"pending_payment"`)
+	assert.Contains(t, w.Body.String(), `"client_secret"`)
+
+	var seat models.Seat
+	env.db.First(&seat)
+	assert.Equal(t, models.Booked, seat.Status)
+	assert.Nil(t, seat.HoldExpiresAt)
+
+	// Only the owner can pay, and only once
+	w = postJSON(other, "/booking/pay/1", ``)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = postJSON(owner, "/booking/pay/1", ``)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Booking confirmed")
+	w = postJSON(owner, "/booking/pay/1", ``)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var booking models.Booking
+	env.db.First(&booking, 1)
+	assert.Equal(t, models.BookingConfirmed, booking.Status)
+}
+
+// Test a declined payment fails the booking and puts its seats back on sale
+func TestConfirmBookingPaymentDeclined(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	env.db.Create(&models.Show{MovieID: 1, Price: money.New(1000, "USD")})
+	env.db.Create(&models.Seat{ShowID: 1, Row: "A", Number: 1, Status: models.Available})
+
+	router := env.router(1)
+	postJSON(router, "/show/hold", `{"show_id":1,"seats":["A1"]}`)
+	w := postJSON(router, "/show/book", `{"show_id":1,"seats":["A1"]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var booking models.Booking
+	env.db.First(&booking, 1)
+	env.payments.Decline(booking.PaymentIntentID)
+
+	w = postJSON(router, "/booking/pay/1", ``)
+	assert.Equal(t, http.StatusPaymentRequired, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"payment_declined"`)
+
+	env.db.First(&booking, 1)
+	assert.Equal(t, models.BookingFailed, booking.Status)
+
+	var seat models.Seat
+	env.db.First(&seat)
+	assert.Equal(t, models.Available, seat.Status)
+}
+
+// Test PaymentWebhook checks signatures and confirms or fails bookings
+func TestPaymentWebhook(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	env.db.Create(&models.Seat{ShowID: 1, Row: "A", Number: 1, Status: models.Booked})
+	env.db.Create(&models.Seat{ShowID: 1, Row: "A", Number: 2, Status: models.Booked})
+	env.db.Create(&models.Booking{UserID: 1, ShowID: 1, Status: models.BookingPendingPayment, PaymentIntentID: "pi_1"})
+	env.db.Create(&models.Booking{UserID: 1, ShowID: 1, Status: models.BookingPendingPayment, PaymentIntentID: "pi_2"})
+	env.db.Exec("INSERT INTO booking_seats (booking_id, seat_id) VALUES (1, 1), (2, 2)")
+
+	router := env.router(0)
+	send := func(event payments.Event, signature string) *httptest.ResponseRecorder {
+		payload, valid, _ := env.payments.SignedEvent(event)
+		if signature == "" {
+			signature = valid
+		}
+		req, _ := http.NewRequest(http.MethodPost, "/payments/webhook", bytes.NewBuffer(payload))
+		req.Header.Set("X-Payment-Signature", signature)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(payments.Event{ID: "ev_1", Type: payments.EventPaymentSucceeded, IntentID: "pi_1"}, "00ff")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = send(payments.Event{ID: "ev_1", Type: payments.EventPaymentSucceeded, IntentID: "pi_1"}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send(payments.Event{ID: "ev_2", Type: payments.EventPaymentFailed, IntentID: "pi_2"}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// A late failure does not undo a confirmed booking
+	w = send(payments.Event{ID: "ev_3", Type: payments.EventPaymentFailed, IntentID: "pi_1"}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Event ignored")
+
+	var confirmed, failed models.Booking
+	env.db.First(&confirmed, 1)
+	env.db.First(&failed, 2)
+	assert.Equal(t, models.BookingConfirmed, confirmed.Status)
+	assert.Equal(t, models.BookingFailed, failed.Status)
+
+	var seats []models.Seat
+	env.db.Order("id").Find(&seats)
+	assert.Equal(t, models.Booked, seats[0].Status)
+	assert.Equal(t, models.Available, seats[1].Status)
+}
+
+// Test BookSeats leaves the seat links of earlier bookings alone
+func TestBookSeatsKeepsEarlierBookings(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	env.db.Create(&models.Show{MovieID: 1, Price: money.New(1000, "USD")})
+	env.db.Create(&models.Seat{ShowID: 1, Row: "A", Number: 1, Status: models.Available})
+	env.db.Create(&models.Seat{ShowID: 1, Row: "A", Number: 2, Status: models.Available})
+
+	router := env.router(1)
+	for _, seat := range []string{"A1", "A2"} {
+		postJSON(router, "/show/hold", `{"show_id":1,"seats":["`+seat+`"]}`)
+		w := postJSON(router, "/show/book", `{"show_id":1,"seats":["`+seat+`"]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	var first models.Booking
+	env.db.Preload("Seats").First(&first, 1)
+	assert.Len(t, first.Seats, 1)
+
+	// A second active link for the same seat is refused by the database
+	active := true
+	err := env.db.Create(&models.BookingSeat{BookingID: 2, SeatID: 1, Active: &active}).Error
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+}
+
+// Test retried bookings with the same Idempotency-Key replay the first response
+func TestIdempotentBooking(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	env.db.Create(&models.Show{MovieID: 1, Price: money.New(1000, "USD")})
+	env.db.Create(&models.Seat{ShowID: 1, Row: "A", Number: 1, Status: models.Available})
+	env.db.Create(&models.Seat{ShowID: 1, Row: "A", Number: 2, Status: models.Available})
+
+	router := env.router(1, middleware.Idempotency(env.db, time.Hour))
+
+	send := func(key string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/show/book", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	postJSON(router, "/show/hold", `{"show_id":1,"seats":["A1","A2"]}`)
+
+	first := send("retry-me", `{"show_id":1,"seats":["A1"]}`)
+	assert.Equal(t, http.StatusOK, first.Code)
+
+	// A retry gets the original answer instead of a second booking or a conflict
+	retry := send("retry-me", `{"show_id":1,"seats":["A1"]}`)
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+
+	var bookings int64
+	env.db.Model(&models.Booking{}).Count(&bookings)
+	assert.Equal(t, int64(1), bookings)
+
+	// The same key cannot be reused for another request
+	w := send("retry-me", `{"show_id":1,"seats":["A2"]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// Not before it expires, whatever the zone of the clock
+	swept, err := middleware.DeleteExpiredIdempotencyKeys(env.db, time.Now().Add(time.Hour-time.Minute).In(time.FixedZone("IST", 330*60)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), swept)
+
+	// Once expired the key is forgotten
+	swept, err = middleware.DeleteExpiredIdempotencyKeys(env.db, time.Now().Add(time.Hour+time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), swept)
+
+	w = send("retry-me", `{"show_id":1,"seats":["A2"]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// Test a request whose handler panics can be retried with the same key
+func TestIdempotencyKeyFreedAfterPanic(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	calls := 0
+	router := gin.New()
+	router.Use(gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	router.Use(withUser(1), middleware.Idempotency(env.db, time.Hour))
+	router.POST("/flaky", func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		c.JSON(http.StatusOK, gin.H{"calls": calls})
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/flaky", bytes.NewBufferString(`{}`))
+		req.Header.Set("Idempotency-Key", "panics-once")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, send().Code)
+	w := send()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"calls":2`)
+}
+
+// Test CancelBooking releases seats and enforces ownership and the cut-off
+func TestCancelBooking(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	clock := time.Date(2025, time.April, 7, 9, 0, 0, 0, time.UTC)
+	env.bookings.Now = func() time.Time { return clock }
+
+	env.db.Create(&models.Show{MovieID: 1, Time: clock.Add(24 * time.Hour)})
+	env.db.Create(&models.Seat{ShowID: 1, Row: "A", Number: 1, Status: models.Booked})
+	env.db.Create(&models.Booking{UserID: 1, ShowID: 1, Status: models.BookingConfirmed})
+	env.db.Exec("INSERT INTO booking_seats (booking_id, seat_id) VALUES (1, 1)")
+
+	owner := env.router(1)
+	other := env.router(2)
+
+	w := postJSON(other, "/booking/cancel/1", `{"reason":"not mine"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Too close to the show
+	clock = clock.Add(23 * time.Hour)
+	w = postJSON(owner, "/booking/cancel/1", `{"reason":"plans changed"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	clock = clock.Add(-23 * time.Hour)
+	w = postJSON(owner, "/booking/cancel/1", `{"reason":"plans changed"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"cancelled"`)
+
+	var booking models.Booking
+	env.db.First(&booking, 1)
+	assert.Equal(t, models.BookingCancelled, booking.Status)
+	assert.Equal(t, uint(1), booking.CancelledBy)
+	assert.Equal(t, "plans changed", booking.CancelReason)
+
+	var seat models.Seat
+	env.db.First(&seat, 1)
+	assert.Equal(t, models.Available, seat.Status)
+
+	w = postJSON(owner, "/booking/cancel/1", ``)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+// Test GetMyBookings filtering and GetMyBooking ownership
+func TestGetMyBookings(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	clock := time.Date(2025, time.April, 7, 9, 0, 0, 0, time.UTC)
+	env.bookings.Now = func() time.Time { return clock }
+
+	env.db.Create(&models.Movie{Title: "Test Movie"})
+	env.db.Create(&models.Show{MovieID: 1, Time: clock.Add(-24 * time.Hour), Price: money.New(1000, "USD")})
+	env.db.Create(&models.Show{MovieID: 1, Time: clock.Add(24 * time.Hour), Price: money.New(1200, "USD")})
+	env.db.Create(&models.Seat{ShowID: 2, Row: "B", Number: 3, Status: models.Booked})
+	env.db.Create(&models.Booking{UserID: 1, ShowID: 1, Status: models.BookingConfirmed})
+	env.db.Create(&models.Booking{UserID: 1, ShowID: 2, Status: models.BookingConfirmed})
+	env.db.Create(&models.Booking{UserID: 2, ShowID: 2, Status: models.BookingConfirmed})
+	env.db.Exec("INSERT INTO booking_seats (booking_id, seat_id) VALUES (2, 1)")
+
+	router := env.router(1)
+
+	w := get(router, "/booking/get?when=upcoming")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":1`)
+	assert.Contains(t, w.Body.String(), `"seats":["B3"]`)
+	assert.Contains(t, w.Body.String(), "Test Movie")
+
+	w = get(router, "/booking/get")
+	assert.Contains(t, w.Body.String(), `"total":2`)
+
+	w = get(router, "/booking/get?when=soon")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"when"`)
+
+	// Another user's booking is off limits
+	w = get(router, "/booking/get/3")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+// Test that catalog management routes in SetupRouter require staff or admin
+func TestRoleProtectedRoutes(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	cfg := config.Default()
+	cfg.JWTSecret = "test-secret"
+	router := SetupRouter(cfg, env.handlers, env.db)
+
+	addMovie := func(token string) int {
+		req, _ := http.NewRequest(http.MethodPost, "/movie/add", bytes.NewBuffer([]byte(`{"title":"Test Movie","duration":120}`)))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	customerToken, _ := service.GenerateToken([]byte(cfg.JWTSecret), 1, "customer@example.com", models.RoleCustomer)
+	staffToken, _ := service.GenerateToken([]byte(cfg.JWTSecret), 2, "staff@example.com", models.RoleStaff)
+
+	assert.Equal(t, http.StatusUnauthorized, addMovie(""))
+	assert.Equal(t, http.StatusForbidden, addMovie(customerToken))
+	assert.Equal(t, http.StatusOK, addMovie(staffToken))
+}
+
+// Test BookSeats prices each seat by its category and stores the breakdown
+func TestBookSeatsPricesByCategory(t *testing.T) {
+	t.Parallel()
+	env := newTestEnv(t)
+
+	env.db.Create(&models.Show{MovieID: 1, Price: money.New(1000, "USD"), Prices: []models.ShowPrice{
+		{Category: models.SeatPremium, Price: money.New(1800, "USD")},
+	}})
+	env.db.Create(&models.Seat{ShowID: 1, Row: "A", Number: 1, Type: models.SeatStandard, Status: models.Available})
+	env.db.Create(&models.Seat{ShowID: 1, Row: "B", Number: 1, Type: models.SeatPremium, Status: models.Available})
+
+	router := env.router(1)
+
+	w := postJSON(router, "/show/hold", `{"show_id":1,"seats":["A1","B1"]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = postJSON(router, "/show/book", `{"show_id":1,"seats":["A1","B1"]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total_price":{"amount":2800,"currency":"USD"}`)
+	assert.Contains(t, w.Body.String(), `"seat":"B1","category":"premium","unit_price":{"amount":1800,"currency":"USD"}`)
+
+	var booking models.Booking
+	env.db.Preload("LineItems").First(&booking)
+	assert.Equal(t, money.New(2800, "USD"), booking.TotalPrice)
+	assert.Len(t, booking.LineItems, 2)
+}
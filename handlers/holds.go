@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ETE3/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HoldSeats places a temporary hold on seats for the logged-in user
+func (h *Handlers) HoldSeats(c *gin.Context) {
+	userID, _ := c.MustGet("id").(uint)
+
+	var holdRequest struct {
+		ShowID uint     `json:"show_id" binding:"required"`
+		Seats  []string `json:"seats" binding:"required,min=1"`
+	}
+
+	if err := c.ShouldBindJSON(&holdRequest); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	expiresAt, err := h.Bookings.Hold(userID, holdRequest.ShowID, holdRequest.Seats)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Seats held",
+		"show_id":    holdRequest.ShowID,
+		"seats":      holdRequest.Seats,
+		"expires_at": expiresAt,
+	})
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ETE3/apperror"
+	"ETE3/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uintQuery reads an optional ID query parameter, 0 if it is missing
+func uintQuery(c *gin.Context, name string) (uint, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, apperror.Validation("invalid_query", "Invalid query parameters", apperror.FieldError{
+			Field: name, Code: "id", Message: "must be a positive number",
+		})
+	}
+	return uint(id), nil
+}
+
+// GetListings returns the upcoming shows in a date range grouped by movie and
+// theater. Every query parameter is optional:
+// from, to (YYYY-MM-DD), timezone, city, theater_id, movie_id, language,
+// format (2D, 3D, IMAX), time_of_day (comma separated morning, afternoon,
+// evening, night), min_price and max_price (decimal amounts such as 12.50).
+func (h *Handlers) GetListings(c *gin.Context) {
+	theaterID, err := uintQuery(c, "theater_id")
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+	movieID, err := uintQuery(c, "movie_id")
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	query := service.ListingQuery{
+		From:      c.Query("from"),
+		To:        c.Query("to"),
+		Timezone:  c.Query("timezone"),
+		City:      c.Query("city"),
+		TheaterID: theaterID,
+		MovieID:   movieID,
+		Language:  c.Query("language"),
+		Format:    strings.ToUpper(c.Query("format")),
+		MinPrice:  c.Query("min_price"),
+		MaxPrice:  c.Query("max_price"),
+	}
+	for _, name := range strings.Split(c.Query("time_of_day"), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			query.TimesOfDay = append(query.TimesOfDay, name)
+		}
+	}
+
+	listings, err := h.Scheduling.Listings(query)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"movies": listings})
+}
@@ -1,9 +1,10 @@
 package handlers
 
 import (
-	"ETE3/db"
+	"ETE3/apperror"
 	"ETE3/models"
-	"log"
+	"ETE3/money"
+	"ETE3/service"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,52 +12,139 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func AddMovie(c *gin.Context) {
-	var movie models.Movie
-	if err := c.ShouldBindJSON(&movie); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+// movieRequest is the body of add and update movie requests. Only these
+// fields can be set by clients, IDs and timestamps are the server's.
+type movieRequest struct {
+	Title         string              `json:"title" binding:"required,max=255"`
+	Duration      int                 `json:"duration" binding:"required,min=1,max=1000"` // in minutes
+	Photo         string              `json:"photo" binding:"max=2048"`
+	Synopsis      string              `json:"synopsis" binding:"max=10000"`
+	Genres        []string            `json:"genres" binding:"max=20,dive,required,max=64"`
+	Language      string              `json:"language" binding:"max=64"`
+	Subtitles     []string            `json:"subtitles" binding:"max=50,dive,required,max=64"`
+	Certification string              `json:"certification" binding:"max=16"`
+	ReleaseDate   *time.Time          `json:"release_date"`
+	Cast          []castMemberRequest `json:"cast" binding:"max=200,dive"`
+	Crew          []crewMemberRequest `json:"crew" binding:"max=200,dive"`
+	TrailerURL    string              `json:"trailer_url" binding:"omitempty,url,max=2048"`
+	Status        string              `json:"status" binding:"omitempty,oneof=coming_soon now_showing archived"`
+}
+
+type castMemberRequest struct {
+	Name      string `json:"name" binding:"required,max=255"`
+	Character string `json:"character" binding:"max=255"`
+}
+
+type crewMemberRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+	Job  string `json:"job" binding:"required,max=255"`
+}
+
+func (r movieRequest) movie() models.Movie {
+	movie := models.Movie{
+		Title:         r.Title,
+		Duration:      r.Duration,
+		Photo:         r.Photo,
+		Synopsis:      r.Synopsis,
+		Genres:        r.Genres,
+		Language:      r.Language,
+		Subtitles:     r.Subtitles,
+		Certification: r.Certification,
+		ReleaseDate:   r.ReleaseDate,
+		TrailerURL:    r.TrailerURL,
+		Status:        r.Status,
+	}
+	for _, member := range r.Cast {
+		movie.Cast = append(movie.Cast, models.CastMember{Name: member.Name, Character: member.Character})
+	}
+	for _, member := range r.Crew {
+		movie.Crew = append(movie.Crew, models.CrewMember{Name: member.Name, Job: member.Job})
+	}
+	return movie
+}
+
+func (h *Handlers) AddMovie(c *gin.Context) {
+	var request movieRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
 		return
 	}
 
-	db.DB.Create(&movie)
+	movie := request.movie()
+	if err := h.Scheduling.AddMovie(&movie); err != nil {
+		apperror.Write(c, err)
+		return
+	}
 	c.JSON(200, gin.H{"message": "Movie added successfully", "movie_id": movie.ID})
 }
 
-func AddShowHandler(c *gin.Context) {
-	var show models.Show
+// UpdateMovie replaces a movie's details, a missing status keeps the current one
+func (h *Handlers) UpdateMovie(c *gin.Context) {
+	id, err := idParam(c, "id")
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
 
-	// Validate request body
-	if err := c.ShouldBindJSON(&show); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var request movieRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
 		return
 	}
 
-	// Save the show to the database
-	if err := db.DB.Create(&show).Error; err != nil {
-		log.Printf("Error adding show: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create show"})
+	movie, err := h.Scheduling.UpdateMovie(id, request.movie())
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "Movie updated successfully", "movie": movie})
+}
 
-	// Generate seats for this show (A1 to J15)
-	rows := "ABCDEFGHIJ" // 10 rows (A to J)
-	seats := []models.Seat{}
+// DeleteMovie removes a movie from the catalog, keeping it for past bookings
+func (h *Handlers) DeleteMovie(c *gin.Context) {
+	id, err := idParam(c, "id")
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
 
-	for _, row := range rows {
-		for num := 1; num <= 15; num++ {
-			seats = append(seats, models.Seat{
-				ShowID: show.ID, // Set ShowID for each seat
-				Row:    string(row),
-				Number: num,
-				Status: models.Available,
-			})
-		}
+	if err := h.Scheduling.DeleteMovie(id); err != nil {
+		apperror.Write(c, err)
+		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "Movie deleted successfully", "movie_id": id})
+}
 
-	// Insert seats into the DB
-	if err := db.DB.Create(&seats).Error; err != nil {
-		log.Printf("Error adding seats for show %d: %v", show.ID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create seats"})
+// showRequest is the body of add show requests
+type showRequest struct {
+	MovieID  uint               `json:"movie_id" binding:"required"`
+	ScreenID uint               `json:"screen_id" binding:"required"`
+	Time     *time.Time         `json:"time" binding:"required"`
+	Format   string             `json:"format" binding:"omitempty,oneof=2D 3D IMAX"`
+	Price    money.Money        `json:"price"`
+	Prices   []models.ShowPrice `json:"prices" binding:"max=10"`
+}
+
+func (h *Handlers) AddShowHandler(c *gin.Context) {
+	var request showRequest
+
+	// Validate request body
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+	show := models.Show{
+		MovieID:  request.MovieID,
+		ScreenID: request.ScreenID,
+		Time:     *request.Time,
+		Format:   request.Format,
+		Price:    request.Price,
+		Prices:   request.Prices,
+	}
+
+	// Save the show along with the seats of its screen
+	if err := h.Scheduling.AddShow(&show); err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
@@ -67,26 +155,93 @@ func AddShowHandler(c *gin.Context) {
 	})
 }
 
-func GetAllMovies(c *gin.Context) {
-	var movies []models.Movie
+// showUpdateRequest is the body of update show requests, fields left out stay as they are
+type showUpdateRequest struct {
+	ScreenID *uint               `json:"screen_id"`
+	Time     *time.Time          `json:"time"`
+	Price    *money.Money        `json:"price"`
+	Prices   *[]models.ShowPrice `json:"prices" binding:"omitempty,max=10"`
+}
 
-	// Fetch all movies from the database
-	if err := db.DB.Find(&movies).Error; err != nil {
-		c.JSON(500, gin.H{"error": "Unable to fetch movies"})
+// UpdateShow reschedules or reprices a show, bookings follow it to its new
+// time or screen
+func (h *Handlers) UpdateShow(c *gin.Context) {
+	id, err := idParam(c, "id")
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
-	// Return the list of movies as JSON
-	c.JSON(200, movies)
+	var request showUpdateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	change, err := h.Scheduling.UpdateShow(c.Request.Context(), id, service.ShowUpdate{
+		ScreenID: request.ScreenID,
+		Time:     request.Time,
+		Price:    request.Price,
+		Prices:   request.Prices,
+	})
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	attention := make([]gin.H, 0)
+	for _, booking := range change.NeedingAttention() {
+		attention = append(attention, gin.H{"booking_id": booking.ID, "reason": booking.AttentionReason})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Show updated successfully",
+		"show":              change.Show,
+		"bookings_affected": len(change.Bookings),
+		"needs_attention":   attention,
+	})
 }
 
-func GetMovie(c *gin.Context) {
-	id := c.Param("id")
-	var movies models.Movie
+// CancelShow cancels a show, its bookings are cancelled and refunded
+func (h *Handlers) CancelShow(c *gin.Context) {
+	userID, _ := c.MustGet("id").(uint)
 
+	id, err := idParam(c, "id")
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	var cancelRequest struct {
+		Reason string `json:"reason" binding:"max=255"`
+	}
+
+	// The body is optional, a cancellation without a reason is fine
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&cancelRequest); err != nil {
+			apperror.Write(c, apperror.FromBinding(err))
+			return
+		}
+	}
+
+	change, err := h.Scheduling.CancelShow(c.Request.Context(), userID, id, cancelRequest.Reason)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Show cancelled",
+		"show":               change.Show,
+		"bookings_cancelled": len(change.Bookings),
+		"refunds":            change.Refunds,
+	})
+}
+
+func (h *Handlers) GetAllMovies(c *gin.Context) {
 	// Fetch all movies from the database
-	if err := db.DB.Where("id = ?", id).First(&movies).Error; err != nil {
-		c.JSON(500, gin.H{"error": "Unable to fetch movies"})
+	movies, err := h.Scheduling.ListMovies()
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
@@ -94,19 +249,33 @@ func GetMovie(c *gin.Context) {
 	c.JSON(200, movies)
 }
 
-func GetShowsByMovie(c *gin.Context) {
-	movieID := c.Param("movie_id") // Retrieve the movie ID from the URL path
+func (h *Handlers) GetMovie(c *gin.Context) {
+	id, err := idParam(c, "id")
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
 
-	// Query for all shows related to the given movie ID
-	var shows []models.Show
-	if err := db.DB.Where("movie_id = ?", movieID).Find(&shows).Error; err != nil {
-		c.JSON(500, gin.H{"error": "Unable to fetch shows"})
+	movie, err := h.Scheduling.GetMovie(id)
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
-	// If no shows are found
-	if len(shows) == 0 {
-		c.JSON(404, gin.H{"message": "No shows found for this movie"})
+	c.JSON(200, movie)
+}
+
+func (h *Handlers) GetShowsByMovie(c *gin.Context) {
+	movieID, err := idParam(c, "movie_id") // Retrieve the movie ID from the URL path
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	// Query for all shows related to the given movie ID
+	shows, err := h.Scheduling.ShowsByMovie(movieID)
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
@@ -114,10 +283,13 @@ func GetShowsByMovie(c *gin.Context) {
 	formattedShows := make([]map[string]interface{}, len(shows))
 	for i, show := range shows {
 		formattedShows[i] = map[string]interface{}{
-			"id":      show.ID,
-			"movieId": show.MovieID,
-			"time":    show.Time.Format(time.RFC3339), // ✅ Ensures proper ISO 8601 format
-			"price":   show.Price,
+			"id":       show.ID,
+			"movieId":  show.MovieID,
+			"screenId": show.ScreenID,
+			"time":     show.Time.Format(time.RFC3339), // ✅ Ensures proper ISO 8601 format
+			"format":   show.Format,
+			"price":    show.Price,
+			"prices":   show.Prices,
 		}
 	}
 
@@ -125,13 +297,17 @@ func GetShowsByMovie(c *gin.Context) {
 	c.JSON(200, gin.H{"shows": formattedShows})
 }
 
-func GetAvailableSeatsHandler(c *gin.Context) {
-	var seats []models.Seat
-	showID := c.Param("show_id") // Extract show_id from URL
+func (h *Handlers) GetAvailableSeatsHandler(c *gin.Context) {
+	showID, err := idParam(c, "show_id") // Extract show_id from URL
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
 
-	// Fetch only available seats for the given show
-	if err := db.DB.Where("show_id = ? AND status = ?", showID, models.Available).Find(&seats).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch available seats"})
+	// Fetch only available seats, along with the show to price them
+	show, seats, err := h.Scheduling.AvailableSeats(showID)
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
 
@@ -144,13 +320,15 @@ func GetAvailableSeatsHandler(c *gin.Context) {
 			"seat_id": seat.Row + strconv.Itoa(seat.Number), // Seat label (e.g., "A1")
 			"row":     seat.Row,
 			"number":  seat.Number,
+			"type":    seat.Type,
+			"price":   show.PriceFor(seat.Type),
 			"status":  seat.Status,
 		})
 	}
 
 	// Response
 	c.JSON(http.StatusOK, gin.H{
-		"show_id":         showID,
+		"show_id":         c.Param("show_id"),
 		"seats":           availableSeats,
 		"total_available": len(seats),
 	})
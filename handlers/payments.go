@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"ETE3/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConfirmBookingPayment captures the payment of a pending booking
+func (h *Handlers) ConfirmBookingPayment(c *gin.Context) {
+	userID, _ := c.MustGet("id").(uint)
+
+	bookingID, err := idParam(c, "id")
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	booking, err := h.Bookings.ConfirmPayment(c.Request.Context(), userID, bookingID)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Booking confirmed",
+		"booking_id":  booking.ID,
+		"total_price": booking.TotalPrice,
+		"status":      booking.Status,
+	})
+}
+
+// PaymentWebhook applies payment results pushed by the provider
+func (h *Handlers) PaymentWebhook(c *gin.Context) {
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		apperror.Write(c, apperror.Validation("invalid_body", "Failed to read request body"))
+		return
+	}
+
+	result, err := h.Bookings.HandlePaymentEvent(payload, c.GetHeader("X-Payment-Signature"))
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	switch {
+	case result.Refund != nil && result.Booking != nil:
+		c.JSON(http.StatusOK, gin.H{"message": "Payment refunded", "booking_id": result.Booking.ID, "status": result.Booking.Status, "refund_status": result.Refund.Status})
+	case result.Refund != nil:
+		c.JSON(http.StatusOK, gin.H{"message": "Refund updated", "booking_id": result.Refund.BookingID, "refund_status": result.Refund.Status})
+	case result.Ignored && result.Booking != nil:
+		c.JSON(http.StatusOK, gin.H{"message": "Event ignored", "status": result.Booking.Status})
+	case result.Ignored:
+		c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Booking updated", "booking_id": result.Booking.ID, "status": result.Booking.Status})
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"ETE3/apperror"
+	"ETE3/models"
+	"ETE3/money"
+	"ETE3/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scheduleRequest is the body of add and update schedule requests
+type scheduleRequest struct {
+	MovieID   uint               `json:"movie_id" binding:"required"`
+	ScreenID  uint               `json:"screen_id" binding:"required"`
+	Weekdays  []string           `json:"weekdays" binding:"required,min=1,max=7,dive,oneof=mon tue wed thu fri sat sun"`
+	Times     []string           `json:"times" binding:"required,min=1,max=24,dive,datetime=15:04"`
+	StartDate string             `json:"start_date" binding:"required,datetime=2006-01-02"`
+	EndDate   string             `json:"end_date" binding:"required,datetime=2006-01-02"`
+	Timezone  string             `json:"timezone" binding:"omitempty,timezone"`
+	Format    string             `json:"format" binding:"omitempty,oneof=2D 3D IMAX"`
+	Price     money.Money        `json:"price"`
+	Prices    []models.ShowPrice `json:"prices" binding:"max=10"`
+}
+
+func (r scheduleRequest) schedule() models.ShowSchedule {
+	return models.ShowSchedule{
+		MovieID:   r.MovieID,
+		ScreenID:  r.ScreenID,
+		Weekdays:  r.Weekdays,
+		Times:     r.Times,
+		StartDate: r.StartDate,
+		EndDate:   r.EndDate,
+		Timezone:  r.Timezone,
+		Format:    r.Format,
+		Price:     r.Price,
+		Prices:    r.Prices,
+	}
+}
+
+// dryRunParam reads the dry_run query parameter, false if it is missing
+func dryRunParam(c *gin.Context) (bool, error) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		return false, apperror.Validation("invalid_query", "Invalid query parameters", apperror.FieldError{
+			Field: "dry_run", Code: "boolean", Message: "must be true or false",
+		})
+	}
+	return dryRun, nil
+}
+
+// schedulePlanResponse describes a saved schedule, or with dryRun the shows it
+// would generate and which of them conflict with shows already on the screen
+func schedulePlanResponse(plan service.SchedulePlan, dryRun bool, message string) gin.H {
+	if dryRun {
+		return gin.H{
+			"message":     "Schedule preview",
+			"dry_run":     true,
+			"schedule":    plan.Schedule,
+			"occurrences": plan.Occurrences,
+			"conflicts":   len(plan.Conflicts()),
+			"replaced":    plan.Replaced,
+		}
+	}
+	return gin.H{
+		"message":  message,
+		"schedule": plan.Schedule,
+		"shows":    plan.Shows(),
+		"replaced": plan.Replaced,
+	}
+}
+
+// AddSchedule creates a recurring schedule and the shows it generates.
+// With ?dry_run=true nothing is saved and the planned shows are returned.
+func (h *Handlers) AddSchedule(c *gin.Context) {
+	dryRun, err := dryRunParam(c)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	var request scheduleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	schedule := request.schedule()
+	plan, err := h.Scheduling.AddSchedule(&schedule, dryRun)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schedulePlanResponse(plan, dryRun, "Schedule created successfully"))
+}
+
+// UpdateSchedule replaces a schedule and regenerates its upcoming shows.
+// With ?dry_run=true nothing is saved and the planned shows are returned.
+func (h *Handlers) UpdateSchedule(c *gin.Context) {
+	id, err := idParam(c, "id")
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+	dryRun, err := dryRunParam(c)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	var request scheduleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	plan, err := h.Scheduling.UpdateSchedule(id, request.schedule(), dryRun)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schedulePlanResponse(plan, dryRun, "Schedule updated successfully"))
+}
+
+// GetSchedule returns a schedule with its upcoming shows
+func (h *Handlers) GetSchedule(c *gin.Context) {
+	id, err := idParam(c, "id")
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	schedule, shows, err := h.Scheduling.GetSchedule(id)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"schedule": schedule, "upcoming_shows": shows})
+}
+
+// CancelSchedule deletes a schedule along with its upcoming shows
+func (h *Handlers) CancelSchedule(c *gin.Context) {
+	id, err := idParam(c, "id")
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	cancelled, err := h.Scheduling.CancelSchedule(id)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule cancelled", "schedule_id": id, "shows_cancelled": cancelled})
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ETE3/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSeatMap returns the full seat map of a show, every seat with its state,
+// category, price and coordinates, along with aisles and gaps. The ETag
+// changes whenever the map does, so clients can poll with If-None-Match and
+// get 304 Not Modified until a seat changes.
+func (h *Handlers) GetSeatMap(c *gin.Context) {
+	showID, err := idParam(c, "show_id")
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	seatMap, err := h.Scheduling.SeatMap(showID)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	body, err := json.Marshal(seatMap)
+	if err != nil {
+		apperror.Write(c, apperror.Internal(err, "Failed to encode seat map"))
+		return
+	}
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256(body))
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. Weak
+// validators match too, as the header is only used to skip unchanged bodies.
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ETE3/apperror"
+	"ETE3/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handlers) AddTheater(c *gin.Context) {
+	var theater models.Theater
+	if err := c.ShouldBindJSON(&theater); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	if err := h.Scheduling.AddTheater(&theater); err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Theater added successfully", "theater_id": theater.ID})
+}
+
+// AddScreen adds a screen with its seat layout to an existing theater
+func (h *Handlers) AddScreen(c *gin.Context) {
+	var screen models.Screen
+	if err := c.ShouldBindJSON(&screen); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	if err := h.Scheduling.AddScreen(&screen); err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Screen added successfully", "screen_id": screen.ID})
+}
+
+func (h *Handlers) GetAllTheaters(c *gin.Context) {
+	theaters, err := h.Scheduling.ListTheaters(c.Query("city"))
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, theaters)
+}
+
+// GetTheater returns a theater with its screens and their layouts
+func (h *Handlers) GetTheater(c *gin.Context) {
+	id, err := idParam(c, "id")
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	theater, err := h.Scheduling.GetTheater(id)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, theater)
+}
@@ -1,58 +1,72 @@
 package handlers
 
 import (
-	"ETE3/db"
-	"ETE3/models"
-	"time"
+	"ETE3/apperror"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func Register(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+// credentials is the body of register and login requests. models.User never
+// reads a password from JSON, so it cannot be bound directly.
+type credentials struct {
+	Name     string `json:"name"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+func (h *Handlers) Register(c *gin.Context) {
+	var input credentials
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
 		return
 	}
 
-	// Hash the password before storing it
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-	user.Password = string(hashedPassword)
-
-	db.DB.Create(&user)
+	if _, err := h.Auth.Register(input.Name, input.Email, input.Password); err != nil {
+		apperror.Write(c, err)
+		return
+	}
 	c.JSON(200, gin.H{"message": "User registered successfully"})
 }
 
-func Login(c *gin.Context) {
-	var user models.User
-	var input models.User
-
+// Login issues a token for valid credentials
+func (h *Handlers) Login(c *gin.Context) {
+	var input credentials
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		apperror.Write(c, apperror.FromBinding(err))
 		return
 	}
 
-	db.DB.Where("email = ?", input.Email).First(&user)
-
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-		c.JSON(401, gin.H{"error": "Invalid credentials"})
+	token, err := h.Auth.Login(input.Email, input.Password)
+	if err != nil {
+		apperror.Write(c, err)
 		return
 	}
-
-	token, _ := GenerateToken(user.ID, user.Email)
 	c.JSON(200, gin.H{"token": token})
 }
 
-var SecretKey = []byte("Siuuuuu_Cristiano_Ritam_Romit")
+// SetUserRole lets an admin change another user's role
+func (h *Handlers) SetUserRole(c *gin.Context) {
+	var roleRequest struct {
+		Role string `json:"role" binding:"required,oneof=customer staff admin"`
+	}
 
-func GenerateToken(userID uint, email string) (string, error) {
-	claims := jwt.MapClaims{
-		"id":    userID,
-		"email": email,
-		"exp":   time.Now().Add(time.Hour * 24).Unix(), // Token valid for 24 hours
+	if err := c.ShouldBindJSON(&roleRequest); err != nil {
+		apperror.Write(c, apperror.FromBinding(err))
+		return
+	}
+
+	userID, err := idParam(c, "id")
+	if err != nil {
+		apperror.Write(c, err)
+		return
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(SecretKey)
+
+	user, err := h.Auth.SetRole(userID, roleRequest.Role)
+	if err != nil {
+		apperror.Write(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated", "user_id": user.ID, "role": user.Role})
 }
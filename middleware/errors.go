@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"fmt"
+
+	"ETE3/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Errors renders errors handlers attached with c.Error but did not write, and
+// turns panics into internal errors, so every failure has the same shape
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				apperror.Write(c, apperror.Internal(fmt.Errorf("panic: %v", r), "Internal server error"))
+			}
+		}()
+
+		c.Next()
+
+		if len(c.Errors) > 0 && !c.Writer.Written() {
+			apperror.Write(c, c.Errors.Last().Err)
+		}
+	}
+}
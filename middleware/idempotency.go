@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"ETE3/apperror"
+	"ETE3/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxIdempotencyKeyLength matches the size of the key column
+const maxIdempotencyKeyLength = 191
+
+// recordingWriter keeps a copy of the response body as it is written
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency makes mutating requests that carry an Idempotency-Key header
+// safe to retry. The first request with a key runs and its response is stored;
+// retries with the same key and body get that response back, while reusing the
+// key for a different request is rejected with 422. Responses are stored in
+// database and replayed for ttl. Keys belong to the user AuthMiddleware
+// stored, so it must run first.
+func Idempotency(database *gorm.DB, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" || c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			apperror.Write(c, apperror.Validation("invalid_idempotency_key", "Idempotency-Key is too long"))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			apperror.Write(c, apperror.Validation("invalid_body", "Failed to read request body"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID, _ := c.MustGet("id").(uint)
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+		hash.Write(body)
+
+		record := models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			RequestHash: hex.EncodeToString(hash.Sum(nil)),
+			ExpiresAt:   time.Now().UTC().Add(ttl), // SQLite compares times as text
+		}
+
+		stored, err := claimIdempotencyKey(database, &record)
+		if err != nil {
+			apperror.Write(c, apperror.Internal(err, "Failed to check Idempotency-Key"))
+			return
+		}
+
+		if stored != nil {
+			switch {
+			case stored.RequestHash != record.RequestHash:
+				apperror.Write(c, apperror.New(apperror.KindUnprocessable, "idempotency_key_reused", "Idempotency-Key was already used for a different request"))
+			case stored.StatusCode == 0:
+				apperror.Write(c, apperror.Conflict("idempotency_key_in_progress", "A request with this Idempotency-Key is still in progress"))
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(stored.StatusCode, "application/json; charset=utf-8", []byte(stored.ResponseBody))
+				c.Abort()
+			}
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		// A handler that panics never finishes the record, drop it so retries
+		// are not told the request is still in progress
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				database.Delete(&record)
+				panic(recovered)
+			}
+		}()
+		c.Next()
+
+		// Server errors are not remembered, so the client can try again
+		if writer.Status() >= http.StatusInternalServerError {
+			database.Delete(&record)
+			return
+		}
+		database.Model(&record).Updates(map[string]interface{}{
+			"status_code":   writer.Status(),
+			"response_body": writer.body.String(),
+		})
+	}
+}
+
+// claimIdempotencyKey stores record as in progress. If the user already used
+// the key, and it has not expired, the stored record is returned instead.
+func claimIdempotencyKey(database *gorm.DB, record *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	for {
+		err := database.Create(record).Error
+		if err == nil {
+			return nil, nil
+		}
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, err
+		}
+
+		var stored models.IdempotencyKey
+		err = database.Where(map[string]interface{}{"user_id": record.UserID, "key": record.Key}).First(&stored).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue // expired and swept in the meantime
+		}
+		if err != nil {
+			return nil, err
+		}
+		if stored.ExpiresAt.After(time.Now()) {
+			return &stored, nil
+		}
+
+		// An expired key starts over
+		if err := database.Delete(&stored).Error; err != nil {
+			return nil, err
+		}
+	}
+}
+
+// DeleteExpiredIdempotencyKeys removes stored responses that expired before now
+func DeleteExpiredIdempotencyKeys(database *gorm.DB, now time.Time) (int64, error) {
+	result := database.Where("expires_at <= ?", now.UTC()).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
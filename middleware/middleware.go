@@ -1,31 +1,28 @@
 package middleware
 
 import (
-	"net/http"
 	"strings"
 
+	"ETE3/apperror"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 )
 
-var SecretKey = []byte("Siuuuuu_Cristiano_Ritam_Romit")
-
-// AuthMiddleware verifies JWT token and extracts user info
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware verifies JWT token signed with secret and extracts user info
+func AuthMiddleware(secret []byte) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get the Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
-			c.Abort()
+			apperror.Write(c, apperror.Unauthorized("missing_token", "Authorization header is missing"))
 			return
 		}
 
 		// Split the token from "Bearer <token>"
 		tokenParts := strings.Split(authHeader, " ")
 		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token format"})
-			c.Abort()
+			apperror.Write(c, apperror.Unauthorized("invalid_token", "Invalid token format"))
 			return
 		}
 
@@ -34,30 +31,45 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Parse and validate token
 		claims := jwt.MapClaims{}
 		token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
-			return SecretKey, nil
+			return secret, nil
 		})
 
 		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
+			apperror.Write(c, apperror.Unauthorized("invalid_token", "Invalid or expired token"))
 			return
 		}
 
 		// Extract user details from token
 		userID, ok := claims["id"].(float64) // JWT stores numbers as float64
 		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			c.Abort()
+			apperror.Write(c, apperror.Unauthorized("invalid_token", "Invalid token claims"))
 			return
 		}
 
 		email, _ := claims["email"].(string)
+		role, _ := claims["role"].(string)
 
 		// Store user details in context
 		c.Set("id", uint(userID)) // Convert float64 to uint
 		c.Set("email", email)
+		c.Set("role", role)
 
 		// Continue request
 		c.Next()
 	}
 }
+
+// RequireRole lets the request through only if AuthMiddleware stored one of the given roles
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		apperror.Write(c, apperror.Forbidden("insufficient_role", "You do not have permission to perform this action"))
+	}
+}
@@ -0,0 +1,103 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Tables as they stood when migrations were introduced
+
+type user0001 struct {
+	gorm.Model
+	Name     string
+	Email    string `gorm:"unique"`
+	Password string
+	Role     string `gorm:"default:customer"`
+}
+
+func (user0001) TableName() string { return "users" }
+
+type movie0001 struct {
+	gorm.Model
+	Title    string
+	Duration int
+	Photo    string
+}
+
+func (movie0001) TableName() string { return "movies" }
+
+type show0001 struct {
+	gorm.Model
+	MovieID uint
+	Time    time.Time
+	Price   float64
+}
+
+func (show0001) TableName() string { return "shows" }
+
+type seat0001 struct {
+	gorm.Model
+	ShowID        uint
+	Row           string
+	Number        int
+	Status        int `gorm:"default:0"`
+	HeldBy        uint
+	HoldExpiresAt *time.Time
+}
+
+func (seat0001) TableName() string { return "seats" }
+
+type booking0001 struct {
+	gorm.Model
+	UserID       uint
+	ShowID       uint
+	Status       string
+	CancelledBy  uint
+	CancelledAt  *time.Time
+	CancelReason string
+}
+
+func (booking0001) TableName() string { return "bookings" }
+
+type bookingSeat0001 struct {
+	BookingID uint `gorm:"primaryKey"`
+	SeatID    uint `gorm:"primaryKey"`
+}
+
+func (bookingSeat0001) TableName() string { return "booking_seats" }
+
+var initialSchema = Migration{
+	Version: 1,
+	Name:    "initial_schema",
+	Up: func(tx *gorm.DB) error {
+		tables := []interface{}{
+			&user0001{},
+			&movie0001{},
+			&show0001{},
+			&seat0001{},
+			&booking0001{},
+			&bookingSeat0001{},
+		}
+		// Databases created by the old AutoMigrate on boot already have some of these
+		for _, table := range tables {
+			if tx.Migrator().HasTable(table) {
+				continue
+			}
+			if err := tx.Migrator().CreateTable(table); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(
+			&bookingSeat0001{},
+			&booking0001{},
+			&seat0001{},
+			&show0001{},
+			&movie0001{},
+			&user0001{},
+		)
+	},
+}
@@ -0,0 +1,73 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+type theater0002 struct {
+	gorm.Model
+	Name    string
+	City    string
+	Address string
+}
+
+func (theater0002) TableName() string { return "theaters" }
+
+type screen0002 struct {
+	gorm.Model
+	TheaterID uint `gorm:"index"`
+	Name      string
+	Layout    string `gorm:"type:text"`
+}
+
+func (screen0002) TableName() string { return "screens" }
+
+type show0002 struct {
+	ScreenID uint `gorm:"index"`
+}
+
+func (show0002) TableName() string { return "shows" }
+
+type seat0002 struct {
+	Type string
+	X    int
+	Y    int
+}
+
+func (seat0002) TableName() string { return "seats" }
+
+var theatersAndScreens = Migration{
+	Version: 2,
+	Name:    "theaters_and_screens",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Migrator().CreateTable(&theater0002{}, &screen0002{}); err != nil {
+			return err
+		}
+		if err := tx.Migrator().AddColumn(&show0002{}, "ScreenID"); err != nil {
+			return err
+		}
+		if err := tx.Migrator().CreateIndex(&show0002{}, "ScreenID"); err != nil {
+			return err
+		}
+		for _, column := range []string{"Type", "X", "Y"} {
+			if err := tx.Migrator().AddColumn(&seat0002{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		for _, column := range []string{"Type", "X", "Y"} {
+			if err := dropColumn(tx, &seat0002{}, column); err != nil {
+				return err
+			}
+		}
+		if err := dropIndex(tx, &show0002{}, "ScreenID"); err != nil {
+			return err
+		}
+		if err := dropColumn(tx, &show0002{}, "ScreenID"); err != nil {
+			return err
+		}
+		return tx.Migrator().DropTable(&screen0002{}, &theater0002{})
+	},
+}
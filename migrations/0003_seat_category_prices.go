@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+type showPrice0003 struct {
+	ID       uint `gorm:"primarykey"`
+	ShowID   uint `gorm:"index"`
+	Category string
+	Price    float64
+}
+
+func (showPrice0003) TableName() string { return "show_prices" }
+
+type booking0003 struct {
+	TotalPrice     float64
+	PriceBreakdown string `gorm:"type:text"`
+}
+
+func (booking0003) TableName() string { return "bookings" }
+
+var seatCategoryPrices = Migration{
+	Version: 3,
+	Name:    "seat_category_prices",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Migrator().CreateTable(&showPrice0003{}); err != nil {
+			return err
+		}
+		for _, column := range []string{"TotalPrice", "PriceBreakdown"} {
+			if err := tx.Migrator().AddColumn(&booking0003{}, column); err != nil {
+				return err
+			}
+		}
+		// Until now every seat cost the show price
+		return tx.Exec(`UPDATE bookings SET total_price =
+			(SELECT shows.price FROM shows WHERE shows.id = bookings.show_id) *
+			(SELECT COUNT(*) FROM booking_seats WHERE booking_seats.booking_id = bookings.id)`).Error
+	},
+	Down: func(tx *gorm.DB) error {
+		for _, column := range []string{"TotalPrice", "PriceBreakdown"} {
+			if err := dropColumn(tx, &booking0003{}, column); err != nil {
+				return err
+			}
+		}
+		return tx.Migrator().DropTable(&showPrice0003{})
+	},
+}
@@ -0,0 +1,195 @@
+package migrations
+
+import (
+	"encoding/json"
+	"math"
+
+	"ETE3/money"
+
+	"gorm.io/gorm"
+)
+
+// LegacyCurrency is the currency existing float prices are assumed to be in
+// when they are converted to minor units
+var LegacyCurrency = "USD"
+
+type show0004 struct {
+	Price         float64
+	PriceAmount   int64
+	PriceCurrency string `gorm:"size:3"`
+}
+
+func (show0004) TableName() string { return "shows" }
+
+type showPrice0004 struct {
+	Price         float64
+	PriceAmount   int64
+	PriceCurrency string `gorm:"size:3"`
+}
+
+func (showPrice0004) TableName() string { return "show_prices" }
+
+type booking0004 struct {
+	TotalPrice         float64
+	TotalPriceAmount   int64
+	TotalPriceCurrency string `gorm:"size:3"`
+}
+
+func (booking0004) TableName() string { return "bookings" }
+
+// Price breakdown entries stored as JSON on bookings, before and after
+type floatSeatPrice0004 struct {
+	Seat     string  `json:"seat"`
+	Category string  `json:"category"`
+	Price    float64 `json:"price"`
+}
+
+type moneySeatPrice0004 struct {
+	Seat     string      `json:"seat"`
+	Category string      `json:"category"`
+	Price    money.Money `json:"price"`
+}
+
+// priceColumns names a float price column and the amount and currency columns replacing it
+type priceColumns struct {
+	table    string
+	float    string
+	amount   string
+	currency string
+}
+
+var priceColumns0004 = []priceColumns{
+	{"shows", "price", "price_amount", "price_currency"},
+	{"show_prices", "price", "price_amount", "price_currency"},
+	{"bookings", "total_price", "total_price_amount", "total_price_currency"},
+}
+
+func toMinorUnits(tx *gorm.DB, columns priceColumns) error {
+	var rows []struct {
+		ID    uint
+		Value float64
+	}
+	if err := tx.Table(columns.table).Select("id, " + columns.float + " AS value").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		m := money.FromFloat(row.Value, LegacyCurrency)
+		if err := tx.Table(columns.table).Where("id = ?", row.ID).Updates(map[string]interface{}{
+			columns.amount:   m.Amount,
+			columns.currency: m.Currency,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toFloat(tx *gorm.DB, columns priceColumns) error {
+	var rows []struct {
+		ID       uint
+		Amount   int64
+		Currency string
+	}
+	if err := tx.Table(columns.table).
+		Select("id, " + columns.amount + " AS amount, " + columns.currency + " AS currency").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		value := float64(row.Amount) / math.Pow10(money.Exponent(row.Currency))
+		if err := tx.Table(columns.table).Where("id = ?", row.ID).Update(columns.float, value).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteBreakdowns converts the JSON price breakdown of every booking
+func rewriteBreakdowns(tx *gorm.DB, convert func(data string) (string, error)) error {
+	var rows []struct {
+		ID             uint
+		PriceBreakdown string
+	}
+	if err := tx.Table("bookings").Select("id, price_breakdown").
+		Where("price_breakdown IS NOT NULL AND price_breakdown <> ''").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		converted, err := convert(row.PriceBreakdown)
+		if err != nil {
+			return err
+		}
+		if err := tx.Table("bookings").Where("id = ?", row.ID).Update("price_breakdown", converted).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var moneyMinorUnits = Migration{
+	Version: 4,
+	Name:    "money_minor_units",
+	Up: func(tx *gorm.DB) error {
+		tables := []interface{}{&show0004{}, &showPrice0004{}, &booking0004{}}
+		for i, columns := range priceColumns0004 {
+			for _, column := range []string{columns.amount, columns.currency} {
+				if err := tx.Migrator().AddColumn(tables[i], column); err != nil {
+					return err
+				}
+			}
+			if err := toMinorUnits(tx, columns); err != nil {
+				return err
+			}
+			if err := dropColumn(tx, tables[i], columns.float); err != nil {
+				return err
+			}
+		}
+
+		return rewriteBreakdowns(tx, func(data string) (string, error) {
+			var old []floatSeatPrice0004
+			if err := json.Unmarshal([]byte(data), &old); err != nil {
+				return "", err
+			}
+			converted := make([]moneySeatPrice0004, 0, len(old))
+			for _, line := range old {
+				converted = append(converted, moneySeatPrice0004{line.Seat, line.Category, money.FromFloat(line.Price, LegacyCurrency)})
+			}
+			out, err := json.Marshal(converted)
+			return string(out), err
+		})
+	},
+	Down: func(tx *gorm.DB) error {
+		tables := []interface{}{&show0004{}, &showPrice0004{}, &booking0004{}}
+		for i, columns := range priceColumns0004 {
+			if err := tx.Migrator().AddColumn(tables[i], columns.float); err != nil {
+				return err
+			}
+			if err := toFloat(tx, columns); err != nil {
+				return err
+			}
+			for _, column := range []string{columns.amount, columns.currency} {
+				if err := dropColumn(tx, tables[i], column); err != nil {
+					return err
+				}
+			}
+		}
+
+		return rewriteBreakdowns(tx, func(data string) (string, error) {
+			var current []moneySeatPrice0004
+			if err := json.Unmarshal([]byte(data), &current); err != nil {
+				return "", err
+			}
+			converted := make([]floatSeatPrice0004, 0, len(current))
+			for _, line := range current {
+				value := float64(line.Price.Amount) / math.Pow10(money.Exponent(line.Price.Currency))
+				converted = append(converted, floatSeatPrice0004{line.Seat, line.Category, value})
+			}
+			out, err := json.Marshal(converted)
+			return string(out), err
+		})
+	},
+}
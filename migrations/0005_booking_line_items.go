@@ -0,0 +1,141 @@
+package migrations
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"ETE3/money"
+
+	"gorm.io/gorm"
+)
+
+type bookingLineItem0005 struct {
+	ID                uint `gorm:"primarykey"`
+	BookingID         uint `gorm:"index"`
+	SeatID            uint
+	Seat              string
+	Category          string
+	UnitPriceAmount   int64
+	UnitPriceCurrency string `gorm:"size:3"`
+	DiscountAmount    int64
+	DiscountCurrency  string `gorm:"size:3"`
+	TaxAmount         int64
+	TaxCurrency       string `gorm:"size:3"`
+	TotalAmount       int64
+	TotalCurrency     string `gorm:"size:3"`
+}
+
+func (bookingLineItem0005) TableName() string { return "booking_line_items" }
+
+type booking0005 struct {
+	PriceBreakdown string `gorm:"type:text"`
+}
+
+func (booking0005) TableName() string { return "bookings" }
+
+var bookingLineItems = Migration{
+	Version: 5,
+	Name:    "booking_line_items",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Migrator().CreateTable(&bookingLineItem0005{}); err != nil {
+			return err
+		}
+
+		// Turn each booking's JSON price breakdown into line items. Breakdowns
+		// never had discounts or taxes, so the price is also the line total.
+		var bookings []struct {
+			ID             uint
+			PriceBreakdown string
+		}
+		if err := tx.Table("bookings").Select("id, price_breakdown").
+			Where("price_breakdown IS NOT NULL AND price_breakdown <> ''").
+			Scan(&bookings).Error; err != nil {
+			return err
+		}
+
+		for _, booking := range bookings {
+			var breakdown []moneySeatPrice0004
+			if err := json.Unmarshal([]byte(booking.PriceBreakdown), &breakdown); err != nil {
+				return fmt.Errorf("booking %d: %w", booking.ID, err)
+			}
+
+			seatIDs, err := bookedSeatIDs0005(tx, booking.ID)
+			if err != nil {
+				return err
+			}
+
+			for _, line := range breakdown {
+				zero := money.Zero(line.Price.Currency)
+				if err := tx.Create(&bookingLineItem0005{
+					BookingID:         booking.ID,
+					SeatID:            seatIDs[line.Seat],
+					Seat:              line.Seat,
+					Category:          line.Category,
+					UnitPriceAmount:   line.Price.Amount,
+					UnitPriceCurrency: line.Price.Currency,
+					DiscountCurrency:  zero.Currency,
+					TaxCurrency:       zero.Currency,
+					TotalAmount:       line.Price.Amount,
+					TotalCurrency:     line.Price.Currency,
+				}).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return dropColumn(tx, &booking0005{}, "PriceBreakdown")
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := tx.Migrator().AddColumn(&booking0005{}, "PriceBreakdown"); err != nil {
+			return err
+		}
+
+		var items []bookingLineItem0005
+		if err := tx.Order("booking_id, id").Find(&items).Error; err != nil {
+			return err
+		}
+
+		breakdowns := make(map[uint][]moneySeatPrice0004)
+		for _, item := range items {
+			breakdowns[item.BookingID] = append(breakdowns[item.BookingID], moneySeatPrice0004{
+				Seat:     item.Seat,
+				Category: item.Category,
+				Price:    money.New(item.UnitPriceAmount, item.UnitPriceCurrency),
+			})
+		}
+
+		for bookingID, breakdown := range breakdowns {
+			data, err := json.Marshal(breakdown)
+			if err != nil {
+				return err
+			}
+			if err := tx.Table("bookings").Where("id = ?", bookingID).Update("price_breakdown", string(data)).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Migrator().DropTable(&bookingLineItem0005{})
+	},
+}
+
+// bookedSeatIDs0005 maps the seat labels of a booking to seat IDs
+func bookedSeatIDs0005(tx *gorm.DB, bookingID uint) (map[string]uint, error) {
+	var seats []struct {
+		ID     uint
+		Row    string
+		Number int
+	}
+	if err := tx.Table("seats").
+		Select("seats.*"). // row is a reserved word in MySQL, so avoid naming it
+		Joins("JOIN booking_seats ON booking_seats.seat_id = seats.id").
+		Where("booking_seats.booking_id = ?", bookingID).
+		Scan(&seats).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]uint, len(seats))
+	for _, seat := range seats {
+		ids[fmt.Sprintf("%s%d", seat.Row, seat.Number)] = seat.ID
+	}
+	return ids, nil
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type booking0006 struct {
+	PaymentIntentID  string `gorm:"index;size:191"`
+	PaymentExpiresAt *time.Time
+}
+
+func (booking0006) TableName() string { return "bookings" }
+
+var bookingPayments = Migration{
+	Version: 6,
+	Name:    "booking_payments",
+	Up: func(tx *gorm.DB) error {
+		for _, column := range []string{"PaymentIntentID", "PaymentExpiresAt"} {
+			if err := tx.Migrator().AddColumn(&booking0006{}, column); err != nil {
+				return err
+			}
+		}
+		return tx.Migrator().CreateIndex(&booking0006{}, "PaymentIntentID")
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropIndex(tx, &booking0006{}, "PaymentIntentID"); err != nil {
+			return err
+		}
+		for _, column := range []string{"PaymentIntentID", "PaymentExpiresAt"} {
+			if err := dropColumn(tx, &booking0006{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type refund0007 struct {
+	ID               uint `gorm:"primarykey"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	DeletedAt        gorm.DeletedAt `gorm:"index"`
+	BookingID        uint           `gorm:"index"`
+	ProviderRefundID string         `gorm:"index;size:191"`
+	AmountAmount     int64
+	AmountCurrency   string `gorm:"size:3"`
+	Status           string
+	FailureReason    string
+}
+
+func (refund0007) TableName() string { return "refunds" }
+
+type booking0007 struct {
+	RefundStatus string
+}
+
+func (booking0007) TableName() string { return "bookings" }
+
+var bookingRefunds = Migration{
+	Version: 7,
+	Name:    "refunds",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Migrator().CreateTable(&refund0007{}); err != nil {
+			return err
+		}
+		return tx.Migrator().AddColumn(&booking0007{}, "RefundStatus")
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropColumn(tx, &booking0007{}, "RefundStatus"); err != nil {
+			return err
+		}
+		return tx.Migrator().DropTable(&refund0007{})
+	},
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import "gorm.io/gorm"
+
+type seat0008 struct {
+	Version uint `gorm:"not null;default:0"`
+}
+
+func (seat0008) TableName() string { return "seats" }
+
+var seatVersions = Migration{
+	Version: 8,
+	Name:    "seat_versions",
+	Up: func(tx *gorm.DB) error {
+		return tx.Migrator().AddColumn(&seat0008{}, "Version")
+	},
+	Down: func(tx *gorm.DB) error {
+		return dropColumn(tx, &seat0008{}, "Version")
+	},
+}
@@ -0,0 +1,59 @@
+package migrations
+
+import "gorm.io/gorm"
+
+type bookingSeat0009 struct {
+	BookingID uint  `gorm:"primaryKey"`
+	SeatID    uint  `gorm:"primaryKey;uniqueIndex:idx_booking_seats_active_seat,priority:1"`
+	Active    *bool `gorm:"uniqueIndex:idx_booking_seats_active_seat,priority:2"`
+}
+
+func (bookingSeat0009) TableName() string { return "booking_seats" }
+
+var activeBookingSeats = Migration{
+	Version: 9,
+	Name:    "active_booking_seats",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Migrator().AddColumn(&bookingSeat0009{}, "Active"); err != nil {
+			return err
+		}
+
+		// Mark the links of bookings that still hold their seats. Should a seat
+		// somehow be in more than one of them, the newest booking keeps it so
+		// the unique index can be built.
+		var links []struct {
+			BookingID uint
+			SeatID    uint
+		}
+		if err := tx.Table("booking_seats").
+			Select("booking_seats.booking_id, booking_seats.seat_id").
+			Joins("JOIN bookings ON bookings.id = booking_seats.booking_id").
+			Where("bookings.status IN ? AND bookings.deleted_at IS NULL", []string{"pending_payment", "confirmed"}).
+			Order("booking_seats.booking_id DESC").
+			Scan(&links).Error; err != nil {
+			return err
+		}
+
+		active := true
+		taken := make(map[uint]bool)
+		for _, link := range links {
+			if taken[link.SeatID] {
+				continue
+			}
+			taken[link.SeatID] = true
+			if err := tx.Model(&bookingSeat0009{}).
+				Where("booking_id = ? AND seat_id = ?", link.BookingID, link.SeatID).
+				Update("active", &active).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Migrator().CreateIndex(&bookingSeat0009{}, "idx_booking_seats_active_seat")
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropIndex(tx, &bookingSeat0009{}, "idx_booking_seats_active_seat"); err != nil {
+			return err
+		}
+		return dropColumn(tx, &bookingSeat0009{}, "Active")
+	},
+}
@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type idempotencyKey0010 struct {
+	ID           uint   `gorm:"primarykey"`
+	UserID       uint   `gorm:"uniqueIndex:idx_idempotency_keys_user_key"`
+	Key          string `gorm:"uniqueIndex:idx_idempotency_keys_user_key;size:191"`
+	Method       string
+	Path         string
+	RequestHash  string
+	StatusCode   int
+	ResponseBody string `gorm:"type:text"`
+	CreatedAt    time.Time
+	ExpiresAt    time.Time `gorm:"index"`
+}
+
+func (idempotencyKey0010) TableName() string { return "idempotency_keys" }
+
+var idempotencyKeys = Migration{
+	Version: 10,
+	Name:    "idempotency_keys",
+	Up: func(tx *gorm.DB) error {
+		return tx.Migrator().CreateTable(&idempotencyKey0010{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&idempotencyKey0010{})
+	},
+}
@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type movie0011 struct {
+	Synopsis      string `gorm:"type:text"`
+	Genres        string `gorm:"type:text"` // JSON array
+	Language      string
+	Subtitles     string `gorm:"type:text"` // JSON array
+	Certification string
+	ReleaseDate   *time.Time
+	Cast          string `gorm:"type:text"` // JSON array of {name, character}
+	Crew          string `gorm:"type:text"` // JSON array of {name, job}
+	TrailerURL    string
+	Status        string `gorm:"index;size:32"`
+}
+
+func (movie0011) TableName() string { return "movies" }
+
+var movie0011Columns = []string{"Synopsis", "Genres", "Language", "Subtitles", "Certification", "ReleaseDate", "Cast", "Crew", "TrailerURL", "Status"}
+
+var movieMetadata = Migration{
+	Version: 11,
+	Name:    "movie_metadata",
+	Up: func(tx *gorm.DB) error {
+		for _, column := range movie0011Columns {
+			if err := tx.Migrator().AddColumn(&movie0011{}, column); err != nil {
+				return err
+			}
+		}
+		if err := tx.Migrator().CreateIndex(&movie0011{}, "Status"); err != nil {
+			return err
+		}
+		// Movies from before statuses existed are already scheduled
+		return tx.Exec("UPDATE movies SET status = ?", "now_showing").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropIndex(tx, &movie0011{}, "Status"); err != nil {
+			return err
+		}
+		for _, column := range movie0011Columns {
+			if err := dropColumn(tx, &movie0011{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,60 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type show0012 struct {
+	ScreenID uint      `gorm:"index:idx_shows_screen_time,priority:1"`
+	Time     time.Time `gorm:"index:idx_shows_screen_time,priority:2"`
+	EndsAt   time.Time `gorm:"index"`
+}
+
+func (show0012) TableName() string { return "shows" }
+
+var showEndTimes = Migration{
+	Version: 12,
+	Name:    "show_end_times",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Migrator().AddColumn(&show0012{}, "EndsAt"); err != nil {
+			return err
+		}
+
+		// Existing shows end when their movie does. The ad and cleaning buffers
+		// are configuration, so shows scheduled from now on are the first to
+		// include them.
+		var shows []struct {
+			ID       uint
+			Time     time.Time
+			Duration int
+		}
+		if err := tx.Table("shows").
+			Select("shows.id, shows.time, movies.duration").
+			Joins("JOIN movies ON movies.id = shows.movie_id").
+			Scan(&shows).Error; err != nil {
+			return err
+		}
+		for _, show := range shows {
+			endsAt := show.Time.Add(time.Duration(show.Duration) * time.Minute)
+			if err := tx.Model(&show0012{}).Where("id = ?", show.ID).Update("ends_at", endsAt).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Migrator().CreateIndex(&show0012{}, "EndsAt"); err != nil {
+			return err
+		}
+		return tx.Migrator().CreateIndex(&show0012{}, "idx_shows_screen_time")
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropIndex(tx, &show0012{}, "idx_shows_screen_time"); err != nil {
+			return err
+		}
+		if err := dropIndex(tx, &show0012{}, "EndsAt"); err != nil {
+			return err
+		}
+		return dropColumn(tx, &show0012{}, "EndsAt")
+	},
+}
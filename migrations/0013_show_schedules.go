@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type showSchedule0013 struct {
+	ID            uint `gorm:"primarykey"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
+	MovieID       uint           `gorm:"index"`
+	ScreenID      uint           `gorm:"index"`
+	Weekdays      string         `gorm:"type:text"` // JSON array
+	Times         string         `gorm:"type:text"` // JSON array
+	StartDate     string         `gorm:"size:10"`
+	EndDate       string         `gorm:"size:10"`
+	Timezone      string         `gorm:"size:64"`
+	PriceAmount   int64
+	PriceCurrency string `gorm:"size:3"`
+	Prices        string `gorm:"type:text"` // JSON array of {category, price}
+}
+
+func (showSchedule0013) TableName() string { return "show_schedules" }
+
+type show0013 struct {
+	ScheduleID *uint `gorm:"index"`
+}
+
+func (show0013) TableName() string { return "shows" }
+
+var showSchedules = Migration{
+	Version: 13,
+	Name:    "show_schedules",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Migrator().CreateTable(&showSchedule0013{}); err != nil {
+			return err
+		}
+		if err := tx.Migrator().AddColumn(&show0013{}, "ScheduleID"); err != nil {
+			return err
+		}
+		return tx.Migrator().CreateIndex(&show0013{}, "ScheduleID")
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropIndex(tx, &show0013{}, "ScheduleID"); err != nil {
+			return err
+		}
+		if err := dropColumn(tx, &show0013{}, "ScheduleID"); err != nil {
+			return err
+		}
+		return tx.Migrator().DropTable(&showSchedule0013{})
+	},
+}
@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type show0014 struct {
+	CancelledAt  *time.Time `gorm:"index"`
+	CancelReason string
+}
+
+func (show0014) TableName() string { return "shows" }
+
+type booking0014 struct {
+	AttentionReason string
+}
+
+func (booking0014) TableName() string { return "bookings" }
+
+var showCancellations = Migration{
+	Version: 14,
+	Name:    "show_cancellations",
+	Up: func(tx *gorm.DB) error {
+		for _, column := range []string{"CancelledAt", "CancelReason"} {
+			if err := tx.Migrator().AddColumn(&show0014{}, column); err != nil {
+				return err
+			}
+		}
+		if err := tx.Migrator().CreateIndex(&show0014{}, "CancelledAt"); err != nil {
+			return err
+		}
+		return tx.Migrator().AddColumn(&booking0014{}, "AttentionReason")
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropColumn(tx, &booking0014{}, "AttentionReason"); err != nil {
+			return err
+		}
+		if err := dropIndex(tx, &show0014{}, "CancelledAt"); err != nil {
+			return err
+		}
+		for _, column := range []string{"CancelledAt", "CancelReason"} {
+			if err := dropColumn(tx, &show0014{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
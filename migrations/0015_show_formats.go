@@ -0,0 +1,39 @@
+package migrations
+
+import "gorm.io/gorm"
+
+type show0015 struct {
+	Format string `gorm:"size:8;default:2D"`
+}
+
+func (show0015) TableName() string { return "shows" }
+
+type showSchedule0015 struct {
+	Format string `gorm:"size:8;default:2D"`
+}
+
+func (showSchedule0015) TableName() string { return "show_schedules" }
+
+var showFormats = Migration{
+	Version: 15,
+	Name:    "show_formats",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.Migrator().AddColumn(&show0015{}, "Format"); err != nil {
+			return err
+		}
+		if err := tx.Migrator().AddColumn(&showSchedule0015{}, "Format"); err != nil {
+			return err
+		}
+		// Every show so far was a plain 2D one
+		if err := tx.Exec("UPDATE shows SET format = ?", "2D").Error; err != nil {
+			return err
+		}
+		return tx.Exec("UPDATE show_schedules SET format = ?", "2D").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := dropColumn(tx, &showSchedule0015{}, "Format"); err != nil {
+			return err
+		}
+		return dropColumn(tx, &show0015{}, "Format")
+	},
+}
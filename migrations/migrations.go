@@ -0,0 +1,207 @@
+package migrations
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one versioned schema change. Migrations describe tables with
+// their own structs rather than the live models, so later model changes never
+// rewrite history.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration records an applied migration in the schema_migrations table
+type SchemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// Status describes whether a migration has been applied
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time // nil while pending
+}
+
+// all lists every migration, oldest first
+var all = []Migration{
+	initialSchema,
+	theatersAndScreens,
+	seatCategoryPrices,
+	moneyMinorUnits,
+	bookingLineItems,
+	bookingPayments,
+	bookingRefunds,
+	seatVersions,
+	activeBookingSeats,
+	idempotencyKeys,
+	movieMetadata,
+	showEndTimes,
+	showSchedules,
+	showCancellations,
+	showFormats,
+}
+
+// All returns every known migration sorted by version
+func All() []Migration {
+	sorted := append([]Migration(nil), all...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return sorted
+}
+
+// applied returns the applied migrations keyed by version
+func applied(db *gorm.DB) (map[int64]SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	var rows []SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[int64]SchemaMigration, len(rows))
+	for _, row := range rows {
+		result[row.Version] = row
+	}
+	return result, nil
+}
+
+// Up applies every pending migration in order and returns the ones it ran
+func Up(db *gorm.DB) ([]Migration, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, migration := range All() {
+		if _, ok := done[migration.Version]; ok {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return ran, fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		ran = append(ran, migration)
+	}
+
+	return ran, nil
+}
+
+// Down reverts the given number of most recently applied migrations
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := All()
+	var reverted []Migration
+	for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		migration := migrations[i]
+		if _, ok := done[migration.Version]; !ok {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("reverting migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		reverted = append(reverted, migration)
+	}
+
+	return reverted, nil
+}
+
+// List reports every known migration and when it was applied
+func List(db *gorm.DB) ([]Status, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, migration := range All() {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if row, ok := done[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// dropColumn drops the named field's column. SQLite drops a column by
+// rebuilding the table, which loses every index on it, so the indexes that
+// do not cover the column are created again.
+func dropColumn(tx *gorm.DB, model interface{}, name string) error {
+	if tx.Dialector.Name() != "sqlite" {
+		return tx.Migrator().DropColumn(model, name)
+	}
+
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	column := name
+	if field := stmt.Schema.LookUpField(name); field != nil {
+		column = field.DBName
+	}
+
+	var indexes []string
+	if err := tx.Raw("SELECT sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", stmt.Table).
+		Scan(&indexes).Error; err != nil {
+		return err
+	}
+	if err := tx.Migrator().DropColumn(model, name); err != nil {
+		return err
+	}
+
+	covers := regexp.MustCompile("[(,]\\s*[`\"]?" + regexp.QuoteMeta(column) + "[`\"]?\\s*[,)]")
+	for _, index := range indexes {
+		if covers.MatchString(index) {
+			continue
+		}
+		if err := tx.Exec(index).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropIndex drops an index unless it is already gone, as dropping a column
+// on SQLite takes the column's indexes with it
+func dropIndex(tx *gorm.DB, model interface{}, name string) error {
+	if !tx.Migrator().HasIndex(model, name) {
+		return nil
+	}
+	return tx.Migrator().DropIndex(model, name)
+}
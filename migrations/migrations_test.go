@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"testing"
+
+	"ETE3/config"
+	"ETE3/db"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// indexes lists the named indexes of the SQLite database
+func indexes(t *testing.T, testDB *gorm.DB) []string {
+	var names []string
+	err := testDB.Raw("SELECT name FROM sqlite_master WHERE type = 'index' AND sql IS NOT NULL ORDER BY name").Scan(&names).Error
+	assert.NoError(t, err)
+	return names
+}
+
+// Test every migration can be reverted and applied again, one at a time and
+// all at once, without losing indexes along the way
+func TestUpDownUp(t *testing.T) {
+	testDB, err := db.Open(config.DatabaseConfig{Driver: db.DriverSQLiteMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ran, err := Up(testDB)
+	assert.NoError(t, err)
+	assert.Len(t, ran, len(all))
+	applied := indexes(t, testDB)
+	assert.Contains(t, applied, "idx_shows_screen_id")
+	assert.Contains(t, applied, "idx_shows_deleted_at")
+
+	for steps := 1; steps <= len(all); steps++ {
+		reverted, err := Down(testDB, steps)
+		assert.NoError(t, err)
+		assert.Len(t, reverted, steps)
+		ran, err := Up(testDB)
+		assert.NoError(t, err)
+		assert.Len(t, ran, steps)
+	}
+
+	reverted, err := Down(testDB, len(all))
+	assert.NoError(t, err)
+	assert.Len(t, reverted, len(all))
+	statuses, err := List(testDB)
+	assert.NoError(t, err)
+	for _, status := range statuses {
+		assert.Nil(t, status.AppliedAt, "migration %d", status.Version)
+	}
+
+	ran, err = Up(testDB)
+	assert.NoError(t, err)
+	assert.Len(t, ran, len(all))
+	assert.Equal(t, applied, indexes(t, testDB))
+}
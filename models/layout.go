@@ -0,0 +1,147 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kinds of position in a layout row
+const (
+	PositionSeat  = "seat"
+	PositionAisle = "aisle" // walkway between seat blocks
+	PositionGap   = "gap"   // empty floor, e.g. a missing seat or a pillar
+)
+
+// Seat categories, set as the seat type in a layout. Shows can price each category differently.
+const (
+	SeatStandard   = "standard"
+	SeatPremium    = "premium"
+	SeatRecliner   = "recliner"
+	SeatWheelchair = "wheelchair"
+	SeatCompanion  = "companion" // next to a wheelchair space
+)
+
+// SeatCategories lists every valid seat category
+var SeatCategories = []string{SeatStandard, SeatPremium, SeatRecliner, SeatWheelchair, SeatCompanion}
+
+// IsSeatCategory reports whether category is one of SeatCategories
+func IsSeatCategory(category string) bool {
+	for _, known := range SeatCategories {
+		if category == known {
+			return true
+		}
+	}
+	return false
+}
+
+// SeatLayout is the seating plan of a screen, front row first
+type SeatLayout struct {
+	Rows []LayoutRow `json:"rows"`
+}
+
+// LayoutRow lists a row's positions from left to right. Rows may differ in length.
+type LayoutRow struct {
+	Label     string           `json:"label"` // e.g., "A"
+	Positions []LayoutPosition `json:"positions"`
+}
+
+type LayoutPosition struct {
+	Kind   string `json:"kind"`             // seat, aisle, gap
+	Number int    `json:"number,omitempty"` // seat number within the row, seats only
+	Type   string `json:"type,omitempty"`   // seat category, standard when empty
+}
+
+// GridLayout builds a plain layout: one row per letter of rows, each with
+// seatsPerRow standard seats and no aisles
+func GridLayout(rows string, seatsPerRow int) SeatLayout {
+	var layout SeatLayout
+	for _, label := range rows {
+		row := LayoutRow{Label: string(label)}
+		for number := 1; number <= seatsPerRow; number++ {
+			row.Positions = append(row.Positions, LayoutPosition{Kind: PositionSeat, Number: number, Type: SeatStandard})
+		}
+		layout.Rows = append(layout.Rows, row)
+	}
+	return layout
+}
+
+// Validate checks that row labels and seat numbers are unique and every position is known
+func (l SeatLayout) Validate() error {
+	if len(l.Rows) == 0 {
+		return errors.New("layout needs at least one row")
+	}
+
+	var problems []string
+	labels := make(map[string]bool)
+	seatCount := 0
+
+	for _, row := range l.Rows {
+		if row.Label == "" {
+			problems = append(problems, "every row needs a label")
+			continue
+		}
+		if labels[row.Label] {
+			problems = append(problems, fmt.Sprintf("row %s appears twice", row.Label))
+		}
+		labels[row.Label] = true
+
+		numbers := make(map[int]bool)
+		for _, position := range row.Positions {
+			switch position.Kind {
+			case PositionSeat:
+				if position.Number < 1 {
+					problems = append(problems, fmt.Sprintf("row %s has a seat without a number", row.Label))
+				} else if numbers[position.Number] {
+					problems = append(problems, fmt.Sprintf("seat %s%d appears twice", row.Label, position.Number))
+				}
+				numbers[position.Number] = true
+				if position.Type != "" && !IsSeatCategory(position.Type) {
+					problems = append(problems, fmt.Sprintf("seat %s%d has unknown category %q", row.Label, position.Number, position.Type))
+				}
+				seatCount++
+			case PositionAisle, PositionGap:
+			default:
+				problems = append(problems, fmt.Sprintf("row %s has unknown position kind %q", row.Label, position.Kind))
+			}
+		}
+	}
+
+	if seatCount == 0 && len(problems) == 0 {
+		problems = append(problems, "layout has no seats")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid layout: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// SeatsForShow generates the show's seat inventory, all available, with
+// coordinates taken from the seat's place in the layout
+func (l SeatLayout) SeatsForShow(showID uint) []Seat {
+	var seats []Seat
+	for y, row := range l.Rows {
+		for x, position := range row.Positions {
+			if position.Kind != PositionSeat {
+				continue
+			}
+
+			seatType := position.Type
+			if seatType == "" {
+				seatType = SeatStandard
+			}
+
+			seats = append(seats, Seat{
+				ShowID: showID,
+				Row:    row.Label,
+				Number: position.Number,
+				Type:   seatType,
+				X:      x,
+				Y:      y,
+				Status: Available,
+			})
+		}
+	}
+	return seats
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"ETE3/money"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
@@ -11,10 +13,11 @@ type bookingStatus int
 const (
 	Available bookingStatus = iota
 	Booked
+	Held
 )
 
 func (s bookingStatus) String() string {
-	return [...]string{"available", "booked"}[s]
+	return [...]string{"available", "booked", "held"}[s]
 }
 
 type Seat struct {
@@ -23,27 +26,225 @@ type Seat struct {
 	Row    string        `json:"row"`    // e.g., "A"
 	Number int           `json:"number"` // e.g., 1-15
 	Status bookingStatus `json:"status" gorm:"default:0"`
+	Type   string        `json:"type"` // seat category from the screen layout
+	X      int           `json:"x"`    // position within the row, aisles and gaps included
+	Y      int           `json:"y"`    // row index, front row is 0
+	// Set while Status is Held: who holds the seat and until when
+	HeldBy        uint       `json:"held_by,omitempty"`
+	HoldExpiresAt *time.Time `json:"hold_expires_at,omitempty"`
+	// Bumped on every status change so concurrent writers can detect each other
+	Version uint `json:"-" gorm:"not null;default:0"`
 }
 
+// User roles, from least to most privileged
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+)
+
 type User struct {
 	gorm.Model
 	Name     string `json:"name"`
 	Email    string `json:"email" gorm:"unique"`
 	Password string `json:"-"`
+	Role     string `json:"role" gorm:"default:customer"` // customer, staff, admin
+}
+
+// Movie statuses
+const (
+	MovieComingSoon = "coming_soon"
+	MovieNowShowing = "now_showing"
+	MovieArchived   = "archived"
+)
+
+// IsMovieStatus reports whether status is one of the movie statuses
+func IsMovieStatus(status string) bool {
+	return status == MovieComingSoon || status == MovieNowShowing || status == MovieArchived
 }
 
 type Movie struct {
 	gorm.Model
-	Title    string `json:"title"`
-	Duration int    `json:"duration"` // in minutes
-	Photo    string `json:"photo"`    // Store the photo URL or file path
+	Title         string       `json:"title"`
+	Duration      int          `json:"duration"` // in minutes
+	Photo         string       `json:"photo"`    // Store the photo URL or file path
+	Synopsis      string       `json:"synopsis" gorm:"type:text"`
+	Genres        []string     `json:"genres" gorm:"serializer:json;type:text"`
+	Language      string       `json:"language"`                                   // original spoken language
+	Subtitles     []string     `json:"subtitles" gorm:"serializer:json;type:text"` // languages subtitles are available in
+	Certification string       `json:"certification"`                              // age rating, e.g. "PG-13"
+	ReleaseDate   *time.Time   `json:"release_date"`
+	Cast          []CastMember `json:"cast" gorm:"serializer:json;type:text"`
+	Crew          []CrewMember `json:"crew" gorm:"serializer:json;type:text"`
+	TrailerURL    string       `json:"trailer_url"`
+	Status        string       `json:"status" gorm:"index;size:32"` // coming_soon, now_showing, archived
+}
+
+// CastMember is an actor and the character they play
+type CastMember struct {
+	Name      string `json:"name"`
+	Character string `json:"character,omitempty"`
+}
+
+// CrewMember is someone behind the camera and their job, e.g. "Director"
+type CrewMember struct {
+	Name string `json:"name"`
+	Job  string `json:"job"`
+}
+
+type Theater struct {
+	gorm.Model
+	Name    string   `json:"name" binding:"required"`
+	City    string   `json:"city" binding:"required"`
+	Address string   `json:"address"`
+	Screens []Screen `json:"screens,omitempty"`
+}
+
+type Screen struct {
+	gorm.Model
+	TheaterID uint       `json:"theater_id" binding:"required"`
+	Name      string     `json:"name" binding:"required"`
+	Layout    SeatLayout `json:"layout" gorm:"serializer:json;type:text"`
+}
+
+// Show formats
+const (
+	Format2D   = "2D"
+	Format3D   = "3D"
+	FormatIMAX = "IMAX"
+)
+
+// IsShowFormat reports whether format is one of the show formats
+func IsShowFormat(format string) bool {
+	return format == Format2D || format == Format3D || format == FormatIMAX
 }
 
 type Show struct {
 	gorm.Model
-	MovieID uint      `json:"movie_id"`
-	Time    time.Time `json:"time"` // Use time.Time for handling date and time
-	Price   float64   `json:"price"`
+	MovieID  uint        `json:"movie_id"`
+	ScreenID uint        `json:"screen_id"`
+	Time     time.Time   `json:"time"`                                        // Use time.Time for handling date and time
+	EndsAt   time.Time   `json:"ends_at" gorm:"index"`                        // when the screen is free again, ads and cleaning included
+	Format   string      `json:"format" gorm:"size:8;default:2D"`             // 2D, 3D or IMAX
+	Price    money.Money `json:"price" gorm:"embedded;embeddedPrefix:price_"` // for seat categories without their own price
+	Prices   []ShowPrice `json:"prices,omitempty"`
+	// Set on shows generated from a schedule
+	ScheduleID *uint `json:"schedule_id,omitempty" gorm:"index"`
+	// Set when the show is cancelled, its bookings are cancelled with it
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty" gorm:"index"`
+	CancelReason string     `json:"cancel_reason,omitempty"`
+}
+
+// ShowSchedule puts a movie on a screen at the same times on chosen days of
+// the week, from StartDate to EndDate. Each occurrence becomes a Show.
+type ShowSchedule struct {
+	gorm.Model
+	MovieID   uint        `json:"movie_id"`
+	ScreenID  uint        `json:"screen_id"`
+	Weekdays  []string    `json:"weekdays" gorm:"serializer:json;type:text"` // "mon" to "sun"
+	Times     []string    `json:"times" gorm:"serializer:json;type:text"`    // HH:MM
+	StartDate string      `json:"start_date" gorm:"size:10"`                 // YYYY-MM-DD
+	EndDate   string      `json:"end_date" gorm:"size:10"`                   // YYYY-MM-DD, inclusive
+	Timezone  string      `json:"timezone" gorm:"size:64"`                   // IANA name the times are in, UTC if empty
+	Format    string      `json:"format" gorm:"size:8;default:2D"`           // of every show, 2D, 3D or IMAX
+	Price     money.Money `json:"price" gorm:"embedded;embeddedPrefix:price_"`
+	Prices    []ShowPrice `json:"prices,omitempty" gorm:"serializer:json;type:text"`
+}
+
+// ShowPrice is what one seat category costs for a show
+type ShowPrice struct {
+	ID       uint        `json:"-" gorm:"primarykey"`
+	ShowID   uint        `json:"-" gorm:"index"`
+	Category string      `json:"category"`
+	Price    money.Money `json:"price" gorm:"embedded;embeddedPrefix:price_"`
+}
+
+// PriceFor returns the price of a seat in the given category. Prices must be loaded.
+func (s Show) PriceFor(category string) money.Money {
+	for _, price := range s.Prices {
+		if price.Category == category {
+			return price.Price
+		}
+	}
+	return s.Price
+}
+
+// BookingSeat links a booking to one of its seats. Active is true while the
+// booking holds on to the seat and NULL once it lets go; a unique index on
+// (seat_id, active) keeps a seat in at most one active booking.
+type BookingSeat struct {
+	BookingID uint  `gorm:"primaryKey"`
+	SeatID    uint  `gorm:"primaryKey"`
+	Active    *bool `json:"-"`
+}
+
+// BookingLineItem is what one seat of a booking was sold for. Receipts and
+// refunds work from these rather than from current show prices.
+type BookingLineItem struct {
+	ID        uint        `json:"-" gorm:"primarykey"`
+	BookingID uint        `json:"-" gorm:"index"`
+	SeatID    uint        `json:"seat_id"`
+	Seat      string      `json:"seat"` // e.g., "A1"
+	Category  string      `json:"category"`
+	UnitPrice money.Money `json:"unit_price" gorm:"embedded;embeddedPrefix:unit_price_"`
+	Discount  money.Money `json:"discount" gorm:"embedded;embeddedPrefix:discount_"`
+	Tax       money.Money `json:"tax" gorm:"embedded;embeddedPrefix:tax_"`
+	Total     money.Money `json:"total" gorm:"embedded;embeddedPrefix:total_"` // unit price - discount + tax
+}
+
+// NewLineItem prices a seat: the discount comes off the unit price and tax,
+// given in basis points (1800 is 18%), is charged on what remains
+func NewLineItem(seat Seat, unitPrice money.Money, discount money.Money, taxRateBPS int64) (BookingLineItem, error) {
+	taxable, err := unitPrice.Sub(discount)
+	if err != nil {
+		return BookingLineItem{}, err
+	}
+	tax := taxable.MulFraction(taxRateBPS, 10000)
+	total, err := taxable.Add(tax)
+	if err != nil {
+		return BookingLineItem{}, err
+	}
+
+	return BookingLineItem{
+		SeatID:    seat.ID,
+		Seat:      fmt.Sprintf("%s%d", seat.Row, seat.Number),
+		Category:  seat.Type,
+		UnitPrice: unitPrice,
+		Discount:  discount,
+		Tax:       tax,
+		Total:     total,
+	}, nil
+}
+
+// Booking statuses. A booking starts out pending_payment and ends up
+// confirmed once paid, or failed or expired if payment does not happen.
+const (
+	BookingPendingPayment = "pending_payment"
+	BookingConfirmed      = "confirmed"
+	BookingExpired        = "expired"
+	BookingFailed         = "failed"
+	BookingCancelled      = "cancelled"
+)
+
+// bookingTransitions lists the statuses each booking status can move to
+var bookingTransitions = map[string][]string{
+	BookingPendingPayment: {BookingConfirmed, BookingExpired, BookingFailed, BookingCancelled},
+	BookingConfirmed:      {BookingCancelled},
+}
+
+// HoldsSeats reports whether a booking in the given status keeps its seats off sale
+func HoldsSeats(status string) bool {
+	return status == BookingPendingPayment || status == BookingConfirmed
+}
+
+// CanTransition reports whether a booking may move from one status to another
+func CanTransition(from string, to string) bool {
+	for _, allowed := range bookingTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
 }
 
 type Booking struct {
@@ -51,5 +252,53 @@ type Booking struct {
 	UserID uint   `json:"user_id"`
 	ShowID uint   `json:"show_id"`
 	Seats  []Seat `json:"seats" gorm:"many2many:booking_seats;"`
-	Status string `json:"status"` // confirmed, cancelled
+	Status string `json:"status"` // pending_payment, confirmed, expired, failed, cancelled
+	// The provider's payment intent, and when an unpaid booking expires
+	PaymentIntentID  string     `json:"payment_intent_id,omitempty" gorm:"index;size:191"`
+	PaymentExpiresAt *time.Time `json:"payment_expires_at,omitempty"`
+	// What was charged at the time of booking: the sum of the line item totals
+	TotalPrice money.Money       `json:"total_price" gorm:"embedded;embeddedPrefix:total_price_"`
+	LineItems  []BookingLineItem `json:"line_items,omitempty"`
+	// Set when the booking is cancelled
+	CancelledBy  uint       `json:"cancelled_by,omitempty"`
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty"`
+	CancelReason string     `json:"cancel_reason,omitempty"`
+	// Status of the latest refund, empty when nothing was refunded
+	RefundStatus string `json:"refund_status,omitempty"`
+	// Set when a change to the show left something for staff to sort out
+	// with the customer, e.g. a seat that does not exist on the new screen
+	AttentionReason string   `json:"attention_reason,omitempty"`
+	Refunds         []Refund `json:"refunds,omitempty"`
+}
+
+// Refund statuses
+const (
+	RefundPending   = "pending"
+	RefundSucceeded = "succeeded"
+	RefundFailed    = "failed"
+)
+
+// Refund is money given back on a cancelled booking
+type Refund struct {
+	gorm.Model
+	BookingID        uint        `json:"booking_id" gorm:"index"`
+	ProviderRefundID string      `json:"provider_refund_id,omitempty" gorm:"index;size:191"`
+	Amount           money.Money `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	Status           string      `json:"status"` // pending, succeeded, failed
+	FailureReason    string      `json:"failure_reason,omitempty"`
+}
+
+// IdempotencyKey remembers the response to a request sent with an
+// Idempotency-Key header so that retries of it get the same answer
+type IdempotencyKey struct {
+	ID           uint   `gorm:"primarykey"`
+	UserID       uint   `gorm:"uniqueIndex:idx_idempotency_keys_user_key"`
+	Key          string `gorm:"uniqueIndex:idx_idempotency_keys_user_key;size:191"`
+	Method       string
+	Path         string
+	RequestHash  string // sha256 of method, path and body
+	StatusCode   int    // 0 while the first request is still running
+	ResponseBody string `gorm:"type:text"`
+	CreatedAt    time.Time
+	ExpiresAt    time.Time `gorm:"index"`
 }
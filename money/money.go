@@ -0,0 +1,177 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in the currency's minor unit (cents for USD) together
+// with its ISO 4217 currency code. Amounts are never floating point.
+//
+// Rounding rule: whenever a value has to be rounded to the minor unit, halves
+// are rounded away from zero (12.345 USD becomes 12.35, -0.005 USD becomes -0.01).
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency" gorm:"size:3"`
+}
+
+var ErrCurrencyMismatch = errors.New("money: currency mismatch")
+
+// exponents lists the currencies whose minor unit is not a hundredth
+var exponents = map[string]int{
+	"BHD": 3, "JOD": 3, "KWD": 3, "OMR": 3, "TND": 3,
+	"CLP": 0, "ISK": 0, "JPY": 0, "KRW": 0, "VND": 0,
+}
+
+// Exponent is the number of decimal places of the currency's minor unit
+func Exponent(currency string) int {
+	if exponent, ok := exponents[currency]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// ValidCurrency reports whether code looks like an ISO 4217 code
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+func Zero(currency string) Money {
+	return Money{Currency: currency}
+}
+
+// Parse reads a decimal string such as "12.50", rounding extra digits to the minor unit
+func Parse(value string, currency string) (Money, error) {
+	exponent := Exponent(currency)
+	text := strings.TrimSpace(value)
+
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+
+	whole, fraction, _ := strings.Cut(text, ".")
+	if whole == "" {
+		whole = "0"
+	}
+	for _, part := range []string{whole, fraction} {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return Money{}, fmt.Errorf("money: invalid amount %q", value)
+			}
+		}
+	}
+
+	// Pad or cut the fraction to the minor unit, remembering the first dropped digit
+	roundUp := false
+	if len(fraction) > exponent {
+		roundUp = fraction[exponent] >= '5'
+		fraction = fraction[:exponent]
+	}
+	fraction += strings.Repeat("0", exponent-len(fraction))
+
+	amount, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("money: invalid amount %q", value)
+	}
+	if roundUp {
+		amount++
+	}
+	if negative {
+		amount = -amount
+	}
+
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// FromFloat converts a legacy floating point amount using its shortest decimal form
+func FromFloat(value float64, currency string) Money {
+	m, _ := Parse(strconv.FormatFloat(value, 'f', -1, 64), currency)
+	return m
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount - other.Amount, Currency: m.Currency}, nil
+}
+
+// Mul multiplies by a whole quantity, e.g. a number of seats
+func (m Money) Mul(quantity int64) Money {
+	return Money{Amount: m.Amount * quantity, Currency: m.Currency}
+}
+
+// MulFraction multiplies by numerator/denominator, rounding halves away from zero.
+// Percentages are MulFraction(p, 100), basis points MulFraction(bp, 10000).
+func (m Money) MulFraction(numerator int64, denominator int64) Money {
+	product := m.Amount * numerator
+	quotient := product / denominator
+	remainder := product % denominator
+	if remainder < 0 {
+		remainder = -remainder
+	}
+	if abs(denominator) <= 2*remainder {
+		if (product < 0) != (denominator < 0) {
+			quotient--
+		} else {
+			quotient++
+		}
+	}
+	return Money{Amount: quotient, Currency: m.Currency}
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Decimal formats the amount in major units, e.g. "12.50"
+func (m Money) Decimal() string {
+	exponent := Exponent(m.Currency)
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+// String formats the amount with its currency, e.g. "12.50 USD"
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
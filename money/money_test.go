@@ -0,0 +1,54 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Parse rounds halves away from zero and respects the currency's minor unit
+func TestParse(t *testing.T) {
+	cases := []struct {
+		value    string
+		currency string
+		amount   int64
+	}{
+		{"12.50", "USD", 1250},
+		{"12.5", "USD", 1250},
+		{"12", "USD", 1200},
+		{"1.005", "USD", 101},
+		{"1.0049", "USD", 100},
+		{"-0.005", "USD", -1},
+		{"1500", "JPY", 1500},
+		{"1.2345", "KWD", 1235},
+	}
+
+	for _, tc := range cases {
+		m, err := Parse(tc.value, tc.currency)
+		assert.NoError(t, err, tc.value)
+		assert.Equal(t, tc.amount, m.Amount, tc.value)
+	}
+
+	_, err := Parse("12,50", "USD")
+	assert.Error(t, err)
+}
+
+// Test arithmetic keeps currencies apart and rounds fractions consistently
+func TestArithmetic(t *testing.T) {
+	price := New(1250, "USD")
+
+	total, err := price.Add(price.Mul(2))
+	assert.NoError(t, err)
+	assert.Equal(t, "37.50 USD", total.String())
+
+	_, err = price.Add(New(100, "EUR"))
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	// 15% of 12.50 is 1.875, rounded to 1.88
+	assert.Equal(t, int64(188), price.MulFraction(15, 100).Amount)
+	assert.Equal(t, int64(-188), New(-1250, "USD").MulFraction(15, 100).Amount)
+
+	assert.Equal(t, "0.05", New(5, "USD").Decimal())
+	assert.Equal(t, "1500", New(1500, "JPY").Decimal())
+	assert.Equal(t, "12.50", FromFloat(12.5, "USD").Decimal())
+}
@@ -0,0 +1,55 @@
+// Package notify tells customers about changes to their bookings
+package notify
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Notice kinds
+const (
+	ShowCancelled   = "show_cancelled"
+	ShowRescheduled = "show_rescheduled"
+)
+
+// Notice is a message to one customer about one of their bookings
+type Notice struct {
+	UserID    uint
+	BookingID uint
+	Kind      string
+	Message   string
+}
+
+// Notifier delivers notices, for example by email
+type Notifier interface {
+	Notify(ctx context.Context, notice Notice) error
+}
+
+// Log writes notices to the log, for running without a delivery channel
+type Log struct{}
+
+func (Log) Notify(ctx context.Context, notice Notice) error {
+	log.Printf("📣 Notifying user %d about booking %d (%s): %s", notice.UserID, notice.BookingID, notice.Kind, notice.Message)
+	return nil
+}
+
+// Memory keeps the notices it is given, for tests
+type Memory struct {
+	mu      sync.Mutex
+	notices []Notice
+}
+
+func (m *Memory) Notify(ctx context.Context, notice Notice) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notices = append(m.notices, notice)
+	return nil
+}
+
+// Notices returns what was sent so far
+func (m *Memory) Notices() []Notice {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Notice(nil), m.notices...)
+}
@@ -0,0 +1,151 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"ETE3/money"
+)
+
+// Fake is an in-process Provider for tests and local development. Every
+// intent is authorized immediately and captures succeed unless declined with Decline.
+// Webhooks are signed with HMAC-SHA256 of the body using the secret.
+type Fake struct {
+	mu       sync.Mutex
+	secret   []byte
+	next     int
+	intents  map[string]*Intent
+	declined map[string]bool
+	refunded map[string]int64 // minor units refunded per intent
+}
+
+func NewFake(secret string) *Fake {
+	return &Fake{
+		secret:   []byte(secret),
+		intents:  make(map[string]*Intent),
+		declined: make(map[string]bool),
+		refunded: make(map[string]int64),
+	}
+}
+
+func (f *Fake) nextID(prefix string) string {
+	f.next++
+	return fmt.Sprintf("%s_fake_%d", prefix, f.next)
+}
+
+func (f *Fake) CreateIntent(ctx context.Context, amount money.Money, reference string) (Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := f.nextID("pi")
+	intent := &Intent{
+		ID:           id,
+		Amount:       amount,
+		Status:       IntentRequiresCapture,
+		ClientSecret: id + "_secret",
+		Reference:    reference,
+	}
+	f.intents[id] = intent
+	return *intent, nil
+}
+
+// Decline makes the next capture of the intent fail, like a rejected card
+func (f *Fake) Decline(intentID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.declined[intentID] = true
+}
+
+func (f *Fake) Capture(ctx context.Context, intentID string) (Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return Intent{}, ErrIntentNotFound
+	}
+
+	switch {
+	case intent.Status == IntentSucceeded:
+	case intent.Status == IntentFailed || intent.Status == IntentCanceled || f.declined[intentID]:
+		intent.Status = IntentFailed
+		return *intent, ErrDeclined
+	default:
+		intent.Status = IntentSucceeded
+	}
+	return *intent, nil
+}
+
+func (f *Fake) Void(ctx context.Context, intentID string) (Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return Intent{}, ErrIntentNotFound
+	}
+	if intent.Status == IntentRequiresCapture {
+		intent.Status = IntentCanceled
+	}
+	return *intent, nil
+}
+
+func (f *Fake) Refund(ctx context.Context, intentID string, amount money.Money) (Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return Refund{}, ErrIntentNotFound
+	}
+	if intent.Status != IntentSucceeded {
+		return Refund{}, fmt.Errorf("payments: intent %s has not been captured", intentID)
+	}
+	if amount.Currency != intent.Amount.Currency {
+		return Refund{}, money.ErrCurrencyMismatch
+	}
+	if amount.Amount <= 0 || f.refunded[intentID]+amount.Amount > intent.Amount.Amount {
+		return Refund{}, fmt.Errorf("payments: refund of %s exceeds what is left on %s", amount, intentID)
+	}
+
+	f.refunded[intentID] += amount.Amount
+	return Refund{ID: f.nextID("re"), IntentID: intentID, Amount: amount, Status: RefundSucceeded}, nil
+}
+
+func (f *Fake) mac(payload []byte) []byte {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// Sign returns the signature the fake expects for a webhook body
+func (f *Fake) Sign(payload []byte) string {
+	return hex.EncodeToString(f.mac(payload))
+}
+
+// SignedEvent encodes an event as a webhook body along with its signature
+func (f *Fake) SignedEvent(event Event) (payload []byte, signature string, err error) {
+	payload, err = json.Marshal(event)
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, f.Sign(payload), nil
+}
+
+func (f *Fake) VerifyWebhook(payload []byte, signature string) (Event, error) {
+	given, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(given, f.mac(payload)) {
+		return Event{}, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return Event{}, fmt.Errorf("payments: invalid webhook body: %w", err)
+	}
+	return event, nil
+}
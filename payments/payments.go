@@ -0,0 +1,76 @@
+package payments
+
+import (
+	"context"
+	"errors"
+
+	"ETE3/money"
+)
+
+// Intent statuses
+const (
+	IntentRequiresCapture = "requires_capture" // authorized, money not taken yet
+	IntentSucceeded       = "succeeded"
+	IntentFailed          = "failed"
+	IntentCanceled        = "canceled" // voided before it was paid
+)
+
+// Refund statuses
+const (
+	RefundPending   = "pending"
+	RefundSucceeded = "succeeded"
+	RefundFailed    = "failed"
+)
+
+// Webhook event types
+const (
+	EventPaymentSucceeded = "payment.succeeded"
+	EventPaymentFailed    = "payment.failed"
+	EventRefundSucceeded  = "refund.succeeded"
+	EventRefundFailed     = "refund.failed"
+)
+
+var (
+	ErrDeclined         = errors.New("payments: payment declined")
+	ErrIntentNotFound   = errors.New("payments: intent not found")
+	ErrInvalidSignature = errors.New("payments: invalid webhook signature")
+)
+
+// Intent is a payment the customer is asked to make
+type Intent struct {
+	ID           string      `json:"id"`
+	Amount       money.Money `json:"amount"`
+	Status       string      `json:"status"`
+	ClientSecret string      `json:"client_secret"` // handed to the client to complete payment with the provider
+	Reference    string      `json:"reference"`     // our identifier, e.g. "booking-42"
+}
+
+type Refund struct {
+	ID       string      `json:"id"`
+	IntentID string      `json:"intent_id"`
+	Amount   money.Money `json:"amount"`
+	Status   string      `json:"status"`
+}
+
+// Event is a verified webhook notification from the provider
+type Event struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	IntentID string `json:"intent_id"`
+	RefundID string `json:"refund_id,omitempty"`
+}
+
+// Provider is a payment gateway
+type Provider interface {
+	// CreateIntent asks the gateway to collect amount, tagged with our reference
+	CreateIntent(ctx context.Context, amount money.Money, reference string) (Intent, error)
+	// Capture takes the money of an authorized intent. ErrDeclined means the payment failed for good.
+	Capture(ctx context.Context, intentID string) (Intent, error)
+	// Void cancels an intent that has not been captured so it can no longer be
+	// paid. An intent captured already is returned as it is, succeeded.
+	Void(ctx context.Context, intentID string) (Intent, error)
+	// Refund gives back part or all of a captured intent
+	Refund(ctx context.Context, intentID string, amount money.Money) (Refund, error)
+	// VerifyWebhook checks the signature of a webhook body and decodes it
+	VerifyWebhook(payload []byte, signature string) (Event, error)
+}
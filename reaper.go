@@ -0,0 +1,47 @@
+package main
+
+import (
+	"ETE3/db"
+	"ETE3/middleware"
+	"ETE3/service"
+	"log"
+	"time"
+)
+
+// startReaper releases expired holds and unpaid bookings, and forgets expired
+// idempotency keys, every interval until the returned stop func is called
+func startReaper(bookings *service.BookingService, interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				released, err := bookings.ReleaseExpiredHolds()
+				if err != nil {
+					log.Printf("❌ Error releasing expired holds: %v", err)
+				} else if released > 0 {
+					log.Printf("✅ Released %d expired seat holds", released)
+				}
+				expired, err := bookings.ExpireUnpaidBookings()
+				if err != nil {
+					log.Printf("❌ Error expiring unpaid bookings: %v", err)
+				} else if expired > 0 {
+					log.Printf("✅ Expired %d unpaid bookings", expired)
+				}
+				swept, err := middleware.DeleteExpiredIdempotencyKeys(db.DB, time.Now())
+				if err != nil {
+					log.Printf("❌ Error deleting expired idempotency keys: %v", err)
+				} else if swept > 0 {
+					log.Printf("✅ Deleted %d expired idempotency keys", swept)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
@@ -0,0 +1,141 @@
+package refunds
+
+import (
+	"context"
+	"time"
+
+	"ETE3/models"
+	"ETE3/money"
+	"ETE3/payments"
+	"ETE3/repository"
+)
+
+// Policy decides how much of a booking is given back when it is cancelled
+type Policy struct {
+	// Cancelling at least this long before the show refunds everything
+	FullRefundBefore time.Duration
+	// Share refunded after that until the show starts, in basis points (5000 is 50%)
+	PartialRefundBPS int64
+}
+
+// Refundable is the part of paid that is given back when cancelling at the
+// given time: all of it early enough, a share of it until the show starts,
+// and nothing once it has started
+func (p Policy) Refundable(paid money.Money, showTime time.Time, at time.Time) money.Money {
+	switch {
+	case !at.Before(showTime):
+		return money.Zero(paid.Currency)
+	case showTime.Sub(at) >= p.FullRefundBefore:
+		return paid
+	default:
+		return paid.MulFraction(p.PartialRefundBPS, 10000)
+	}
+}
+
+// Service records refunds and issues them through a payment provider
+type Service struct {
+	Provider payments.Provider
+	Policy   Policy
+}
+
+// Prepare records a pending refund for a booking being cancelled at the given
+// time. It returns nil when nothing is owed, for example when the booking was
+// never paid for or the show has started.
+func (s *Service) Prepare(refunds repository.RefundRepository, booking models.Booking, show models.Show, at time.Time) (*models.Refund, error) {
+	if booking.Status != models.BookingConfirmed || booking.PaymentIntentID == "" {
+		return nil, nil
+	}
+
+	return record(refunds, booking, s.Policy.Refundable(booking.TotalPrice, show.Time, at))
+}
+
+// PrepareFull records a pending refund of everything paid for a booking, for
+// when the theater cancels rather than the customer. It returns nil when the
+// booking was never paid for.
+func (s *Service) PrepareFull(refunds repository.RefundRepository, booking models.Booking) (*models.Refund, error) {
+	if booking.Status != models.BookingConfirmed || booking.PaymentIntentID == "" {
+		return nil, nil
+	}
+	return record(refunds, booking, booking.TotalPrice)
+}
+
+// PrepareReversal records a refund of everything paid for a booking that was
+// never confirmed, for a payment that went through after the booking expired,
+// failed or was cancelled. It returns nil if there is nothing to give back or
+// the payment is already being given back.
+func (s *Service) PrepareReversal(refunds repository.RefundRepository, booking models.Booking) (*models.Refund, error) {
+	if booking.Status == models.BookingConfirmed || booking.PaymentIntentID == "" {
+		return nil, nil
+	}
+	if booking.RefundStatus == models.RefundPending || booking.RefundStatus == models.RefundSucceeded {
+		return nil, nil
+	}
+	return record(refunds, booking, booking.TotalPrice)
+}
+
+func record(refunds repository.RefundRepository, booking models.Booking, amount money.Money) (*models.Refund, error) {
+	if amount.IsZero() {
+		return nil, nil
+	}
+
+	refund := models.Refund{BookingID: booking.ID, Amount: amount, Status: models.RefundPending}
+	if err := refunds.Create(&refund); err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// Issue sends a pending refund to the provider and stores the outcome. A
+// provider error is recorded on the refund rather than returned, only database
+// errors are returned.
+func (s *Service) Issue(ctx context.Context, store repository.Store, refund *models.Refund) error {
+	booking, err := store.Bookings().Get(refund.BookingID)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.Provider.Refund(ctx, booking.PaymentIntentID, refund.Amount)
+	if err != nil {
+		refund.Status = models.RefundFailed
+		refund.FailureReason = err.Error()
+	} else {
+		refund.ProviderRefundID = result.ID
+		refund.Status = providerStatus(result.Status)
+	}
+
+	return store.Refunds().Save(refund)
+}
+
+// Settle applies a refund result the provider reported later, through a
+// webhook. It returns repository.ErrNotFound for refunds it does not know.
+func (s *Service) Settle(store repository.Store, providerRefundID string, status string) (*models.Refund, error) {
+	var refund models.Refund
+	err := store.Transaction(func(tx repository.Store) error {
+		var err error
+		refund, err = tx.Refunds().GetByProviderID(providerRefundID)
+		if err != nil {
+			return err
+		}
+		if refund.Status != models.RefundPending {
+			return nil
+		}
+		refund.Status = providerStatus(status)
+		return tx.Refunds().Save(&refund)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// providerStatus maps a provider refund status onto ours
+func providerStatus(status string) string {
+	switch status {
+	case payments.RefundSucceeded:
+		return models.RefundSucceeded
+	case payments.RefundFailed:
+		return models.RefundFailed
+	default:
+		return models.RefundPending
+	}
+}
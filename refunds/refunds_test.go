@@ -0,0 +1,34 @@
+package refunds
+
+import (
+	"testing"
+	"time"
+
+	"ETE3/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Refundable gives everything back early, a share later and nothing once the show starts
+func TestRefundable(t *testing.T) {
+	policy := Policy{FullRefundBefore: 24 * time.Hour, PartialRefundBPS: 5000}
+	show := time.Date(2025, time.April, 7, 18, 0, 0, 0, time.UTC)
+	paid := money.New(1475, "USD")
+
+	cases := []struct {
+		before time.Duration
+		amount int64
+	}{
+		{48 * time.Hour, 1475},
+		{24 * time.Hour, 1475},
+		{23 * time.Hour, 738},
+		{time.Minute, 738},
+		{0, 0},
+		{-time.Hour, 0},
+	}
+
+	for _, tc := range cases {
+		refund := policy.Refundable(paid, show, show.Add(-tc.before))
+		assert.Equal(t, money.New(tc.amount, "USD"), refund, tc.before.String())
+	}
+}
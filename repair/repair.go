@@ -0,0 +1,104 @@
+package repair
+
+import (
+	"ETE3/models"
+
+	"gorm.io/gorm"
+)
+
+// BookingSeatsResult reports what BookingSeats changed and what it could not fix
+type BookingSeatsResult struct {
+	LinksCreated   int    // links restored from booking line items
+	LinksActivated int    // existing links of live bookings marked active again
+	Orphaned       []uint // booked seats that no live booking could be found for
+}
+
+// BookingSeats rebuilds booking_seats, which older versions used to wipe on
+// every booking. Line items remember which seat every booking bought, so each
+// missing link is restored from them, and a link is made active when its
+// booking still holds the seat and the seat is still booked. Booked seats left
+// without an active link are reported rather than guessed at.
+func BookingSeats(db *gorm.DB) (BookingSeatsResult, error) {
+	var result BookingSeatsResult
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var bookedSeatIDs []uint
+		if err := tx.Model(&models.Seat{}).Where("status = ?", models.Booked).Pluck("id", &bookedSeatIDs).Error; err != nil {
+			return err
+		}
+		booked := make(map[uint]bool, len(bookedSeatIDs))
+		for _, id := range bookedSeatIDs {
+			booked[id] = true
+		}
+
+		var links []models.BookingSeat
+		if err := tx.Find(&links).Error; err != nil {
+			return err
+		}
+		type key struct{ bookingID, seatID uint }
+		existing := make(map[key]models.BookingSeat, len(links))
+		activeSeats := make(map[uint]bool)
+		for _, link := range links {
+			existing[key{link.BookingID, link.SeatID}] = link
+			if link.Active != nil {
+				activeSeats[link.SeatID] = true
+			}
+		}
+
+		// Newest bookings first, so the latest sale of a seat wins
+		var sold []struct {
+			BookingID uint
+			SeatID    uint
+			Status    string
+		}
+		if err := tx.Table("booking_line_items").
+			Select("booking_line_items.booking_id, booking_line_items.seat_id, bookings.status").
+			Joins("JOIN bookings ON bookings.id = booking_line_items.booking_id").
+			Where("booking_line_items.seat_id <> 0 AND bookings.deleted_at IS NULL").
+			Order("booking_line_items.booking_id DESC").
+			Scan(&sold).Error; err != nil {
+			return err
+		}
+
+		active := true
+		for _, item := range sold {
+			claim := models.HoldsSeats(item.Status) && booked[item.SeatID] && !activeSeats[item.SeatID]
+
+			link, found := existing[key{item.BookingID, item.SeatID}]
+			switch {
+			case !found:
+				link = models.BookingSeat{BookingID: item.BookingID, SeatID: item.SeatID}
+				if claim {
+					link.Active = &active
+				}
+				if err := tx.Create(&link).Error; err != nil {
+					return err
+				}
+				existing[key{item.BookingID, item.SeatID}] = link
+				result.LinksCreated++
+			case claim && link.Active == nil:
+				if err := tx.Model(&models.BookingSeat{}).
+					Where("booking_id = ? AND seat_id = ?", item.BookingID, item.SeatID).
+					Update("active", &active).Error; err != nil {
+					return err
+				}
+				result.LinksActivated++
+			default:
+				continue
+			}
+
+			if claim {
+				activeSeats[item.SeatID] = true
+			}
+		}
+
+		for _, id := range bookedSeatIDs {
+			if !activeSeats[id] {
+				result.Orphaned = append(result.Orphaned, id)
+			}
+		}
+		return nil
+	})
+
+	return result, err
+}
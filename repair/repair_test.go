@@ -0,0 +1,51 @@
+package repair
+
+import (
+	"ETE3/config"
+	"ETE3/db"
+	"ETE3/migrations"
+	"ETE3/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test BookingSeats restores wiped links from line items and reports what it cannot place
+func TestBookingSeats(t *testing.T) {
+	testDB, _ := db.Open(config.DatabaseConfig{Driver: db.DriverSQLiteMemory})
+	_, err := migrations.Up(testDB)
+	assert.NoError(t, err)
+
+	// Seat 1 was sold to a confirmed booking, seat 2 to one cancelled since,
+	// seat 3 has an inactive link and seat 4 has no record of a sale at all
+	for number := 1; number <= 4; number++ {
+		testDB.Create(&models.Seat{ShowID: 1, Row: "A", Number: number, Status: models.Booked})
+	}
+	testDB.Create(&models.Booking{UserID: 1, ShowID: 1, Status: models.BookingConfirmed,
+		LineItems: []models.BookingLineItem{{SeatID: 1, Seat: "A1"}}})
+	testDB.Create(&models.Booking{UserID: 2, ShowID: 1, Status: models.BookingCancelled,
+		LineItems: []models.BookingLineItem{{SeatID: 2, Seat: "A2"}}})
+	testDB.Create(&models.Booking{UserID: 3, ShowID: 1, Status: models.BookingPendingPayment,
+		LineItems: []models.BookingLineItem{{SeatID: 3, Seat: "A3"}}})
+	testDB.Create(&models.BookingSeat{BookingID: 3, SeatID: 3})
+
+	result, err := BookingSeats(testDB)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.LinksCreated)
+	assert.Equal(t, 1, result.LinksActivated)
+	assert.Equal(t, []uint{2, 4}, result.Orphaned)
+
+	var active int64
+	testDB.Model(&models.BookingSeat{}).Where("active IS NOT NULL").Count(&active)
+	assert.Equal(t, int64(2), active)
+
+	var booking models.Booking
+	testDB.Preload("Seats").First(&booking, 1)
+	assert.Len(t, booking.Seats, 1)
+
+	// Running it again changes nothing
+	again, err := BookingSeats(testDB)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, again.LinksCreated+again.LinksActivated)
+	assert.Equal(t, []uint{2, 4}, again.Orphaned)
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"time"
+
+	"ETE3/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type bookingRepository struct {
+	db *gorm.DB
+}
+
+func (r bookingRepository) Create(booking *models.Booking) error {
+	return translate(r.db.Create(booking).Error)
+}
+
+func (r bookingRepository) Get(id uint) (models.Booking, error) {
+	var booking models.Booking
+	err := r.db.First(&booking, id).Error
+	return booking, translate(err)
+}
+
+func (r bookingRepository) Lock(id uint) (models.Booking, error) {
+	var booking models.Booking
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, id).Error
+	return booking, translate(err)
+}
+
+func (r bookingRepository) GetDetailed(id uint) (models.Booking, error) {
+	var booking models.Booking
+	err := r.db.Preload("Seats").Preload("LineItems").Preload("Refunds").First(&booking, id).Error
+	return booking, translate(err)
+}
+
+func (r bookingRepository) GetByPaymentIntent(intentID string) (models.Booking, error) {
+	var booking models.Booking
+	err := r.db.Where("payment_intent_id = ?", intentID).First(&booking).Error
+	return booking, translate(err)
+}
+
+func (r bookingRepository) ListForUser(userID uint, filter BookingFilter, page int, pageSize int) ([]models.Booking, int64, error) {
+	query := r.db.Model(&models.Booking{}).
+		Joins("JOIN shows ON shows.id = bookings.show_id").
+		Where("bookings.user_id = ?", userID)
+	if filter.ShowsAfter != nil {
+		query = query.Where("shows.time > ?", filter.ShowsAfter.UTC())
+	}
+	if filter.ShowsBefore != nil {
+		query = query.Where("shows.time <= ?", filter.ShowsBefore.UTC())
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, translate(err)
+	}
+
+	var bookings []models.Booking
+	err := query.Preload("Seats").
+		Order("shows.time DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&bookings).Error
+	return bookings, total, translate(err)
+}
+
+func (r bookingRepository) ListActiveByShow(showID uint) ([]models.Booking, error) {
+	var bookings []models.Booking
+	err := r.db.Preload("Seats").
+		Where("show_id = ? AND status IN ?", showID, []string{models.BookingPendingPayment, models.BookingConfirmed}).
+		Order("id").Find(&bookings).Error
+	return bookings, translate(err)
+}
+
+func (r bookingRepository) Update(id uint, updates map[string]interface{}) error {
+	return translate(r.db.Model(&models.Booking{}).Where("id = ?", id).Updates(updates).Error)
+}
+
+func (r bookingRepository) LinkSeats(bookingID uint, seatIDs []uint) error {
+	active := true
+	for _, seatID := range seatIDs {
+		if err := r.db.Create(&models.BookingSeat{BookingID: bookingID, SeatID: seatID, Active: &active}).Error; err != nil {
+			return translate(err)
+		}
+	}
+	return nil
+}
+
+func (r bookingRepository) MoveSeat(bookingID uint, fromSeatID uint, toSeatID uint) error {
+	if err := r.db.Model(&models.BookingSeat{}).
+		Where("booking_id = ? AND seat_id = ?", bookingID, fromSeatID).
+		Update("active", nil).Error; err != nil {
+		return translate(err)
+	}
+	if err := r.db.Model(&models.BookingLineItem{}).
+		Where("booking_id = ? AND seat_id = ?", bookingID, fromSeatID).
+		Update("seat_id", toSeatID).Error; err != nil {
+		return translate(err)
+	}
+	return r.LinkSeats(bookingID, []uint{toSeatID})
+}
+
+func (r bookingRepository) ReleaseSeats(bookingID uint) error {
+	if err := r.db.Exec("UPDATE seats SET status = ?, version = version + 1 WHERE id IN (SELECT seat_id FROM booking_seats WHERE booking_id = ?)",
+		models.Available, bookingID).Error; err != nil {
+		return translate(err)
+	}
+	return translate(r.db.Model(&models.BookingSeat{}).Where("booking_id = ?", bookingID).Update("active", nil).Error)
+}
+
+func (r bookingRepository) ExpireUnpaid(now time.Time) (int64, error) {
+	var expired int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the overdue bookings once, so one paid for meanwhile is either
+		// settled before this or waits and finds it expired
+		var ids []uint
+		if err := tx.Model(&models.Booking{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status = ? AND payment_expires_at <= ?", models.BookingPendingPayment, now.UTC()).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		result := tx.Model(&models.Booking{}).
+			Where("id IN ? AND status = ?", ids, models.BookingPendingPayment).
+			Update("status", models.BookingExpired)
+		if result.Error != nil {
+			return result.Error
+		}
+		expired = result.RowsAffected
+
+		if err := tx.Exec("UPDATE seats SET status = ?, version = version + 1 WHERE id IN (SELECT seat_id FROM booking_seats WHERE booking_id IN ? AND active = ?)",
+			models.Available, ids, true).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.BookingSeat{}).Where("booking_id IN ?", ids).Update("active", nil).Error
+	})
+	return expired, translate(err)
+}
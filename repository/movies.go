@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"ETE3/models"
+
+	"gorm.io/gorm"
+)
+
+type movieRepository struct {
+	db *gorm.DB
+}
+
+func (r movieRepository) Create(movie *models.Movie) error {
+	return translate(r.db.Create(movie).Error)
+}
+
+func (r movieRepository) Get(id uint) (models.Movie, error) {
+	var movie models.Movie
+	err := r.db.First(&movie, id).Error
+	return movie, translate(err)
+}
+
+func (r movieRepository) List() ([]models.Movie, error) {
+	var movies []models.Movie
+	err := r.db.Find(&movies).Error
+	return movies, translate(err)
+}
+
+func (r movieRepository) ListByIDs(ids []uint) ([]models.Movie, error) {
+	var movies []models.Movie
+	err := r.db.Where("id IN ?", ids).Find(&movies).Error
+	return movies, translate(err)
+}
+
+func (r movieRepository) Update(movie *models.Movie) error {
+	return translate(r.db.Save(movie).Error)
+}
+
+func (r movieRepository) Delete(id uint) error {
+	return translate(r.db.Delete(&models.Movie{}, id).Error)
+}
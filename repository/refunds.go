@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"ETE3/models"
+
+	"gorm.io/gorm"
+)
+
+type refundRepository struct {
+	db *gorm.DB
+}
+
+func (r refundRepository) Create(refund *models.Refund) error {
+	return r.Save(refund)
+}
+
+func (r refundRepository) Save(refund *models.Refund) error {
+	return translate(r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(refund).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Booking{}).Where("id = ?", refund.BookingID).
+			Update("refund_status", refund.Status).Error
+	}))
+}
+
+func (r refundRepository) GetByProviderID(providerRefundID string) (models.Refund, error) {
+	var refund models.Refund
+	err := r.db.Where("provider_refund_id = ?", providerRefundID).First(&refund).Error
+	return refund, translate(err)
+}
@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"ETE3/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFound means the record does not exist
+	ErrNotFound = errors.New("repository: not found")
+	// ErrConflict means a write lost to a concurrent one or broke a unique constraint
+	ErrConflict = errors.New("repository: conflict")
+)
+
+type MovieRepository interface {
+	Create(movie *models.Movie) error
+	Get(id uint) (models.Movie, error)
+	List() ([]models.Movie, error)
+	ListByIDs(ids []uint) ([]models.Movie, error)
+	// Update saves every field of the movie
+	Update(movie *models.Movie) error
+	// Delete soft-deletes the movie, it disappears from Get and List but
+	// bookings can still refer to it
+	Delete(id uint) error
+}
+
+type TheaterRepository interface {
+	Create(theater *models.Theater) error
+	// Get returns the theater with its screens
+	Get(id uint) (models.Theater, error)
+	// List returns every theater, or those in city if it is not empty. Cities
+	// match case-insensitively, as in ShowFilter.
+	List(city string) ([]models.Theater, error)
+	ListByIDs(ids []uint) ([]models.Theater, error)
+	CreateScreen(screen *models.Screen) error
+	GetScreen(id uint) (models.Screen, error)
+	ListScreensByIDs(ids []uint) ([]models.Screen, error)
+	// LockScreen is GetScreen that also locks the screen until the transaction
+	// ends, so shows scheduled on it at the same time are checked one by one
+	LockScreen(id uint) (models.Screen, error)
+}
+
+// ShowFilter narrows down the shows for listings. Cancelled shows and those
+// of deleted movies, theaters or screens are always left out.
+type ShowFilter struct {
+	After     time.Time // shows starting after this
+	Before    time.Time // and before this
+	City      string
+	TheaterID uint
+	MovieID   uint
+	Language  string // the movie's, case insensitive
+	Format    string
+}
+
+type ShowRepository interface {
+	Create(show *models.Show) error
+	// Get returns the show with its category prices
+	Get(id uint) (models.Show, error)
+	// ListByMovie returns the movie's shows that start after the given time
+	// and are not cancelled, earliest first
+	ListByMovie(movieID uint, after time.Time) ([]models.Show, error)
+	// Search returns the shows for listings, earliest first
+	Search(filter ShowFilter) ([]models.Show, error)
+	// ListByIDs returns the shows, cancelled ones included
+	ListByIDs(ids []uint) ([]models.Show, error)
+	// CountByMovieAfter counts the movie's shows, not cancelled, that start after the given time
+	CountByMovieAfter(movieID uint, after time.Time) (int64, error)
+	// ListOverlapping returns the shows keeping the screen busy at some point
+	// between start and end, earliest first. Cancelled shows free their screen.
+	ListOverlapping(screenID uint, start time.Time, end time.Time) ([]models.Show, error)
+	// ListBySchedule returns the shows generated from a schedule that start
+	// after the given time and are not cancelled, earliest first
+	ListBySchedule(scheduleID uint, after time.Time) ([]models.Show, error)
+	Update(id uint, updates map[string]interface{}) error
+	// ReplacePrices swaps the show's category prices for the given ones
+	ReplacePrices(showID uint, prices []models.ShowPrice) error
+	// DeleteMany soft-deletes the shows with their seats, and deletes their
+	// category prices
+	DeleteMany(ids []uint) error
+}
+
+type ScheduleRepository interface {
+	Create(schedule *models.ShowSchedule) error
+	Get(id uint) (models.ShowSchedule, error)
+	// Update saves every field of the schedule
+	Update(schedule *models.ShowSchedule) error
+	// Delete soft-deletes the schedule, the shows it generated keep pointing to it
+	Delete(id uint) error
+}
+
+type SeatRepository interface {
+	CreateMany(seats []models.Seat) error
+	ListByShow(showID uint) ([]models.Seat, error)
+	ListAvailable(showID uint) ([]models.Seat, error)
+	// CountAvailable counts the seats of each show that are on sale at now,
+	// available or held past their hold. Shows without any are left out.
+	CountAvailable(showIDs []uint, now time.Time) (map[uint]int64, error)
+	// CountTaken counts the seats of the shows that are booked or held past
+	// now. Seats whose hold has lapsed are not taken.
+	CountTaken(showIDs []uint, now time.Time) (int64, error)
+	// DeleteByShow soft-deletes the show's seats, links from bookings stay as history
+	DeleteByShow(showID uint) error
+	// Update applies updates only if the seat is unchanged since it was read,
+	// and returns ErrConflict otherwise
+	Update(seat models.Seat, updates map[string]interface{}) error
+	// ReleaseExpiredHolds makes seats whose hold ran out before now available again
+	ReleaseExpiredHolds(now time.Time) (int64, error)
+}
+
+type UserRepository interface {
+	// Create returns ErrConflict if the email is taken
+	Create(user *models.User) error
+	Get(id uint) (models.User, error)
+	GetByEmail(email string) (models.User, error)
+	SetRole(id uint, role string) error
+}
+
+// BookingFilter narrows down a user's bookings by show time
+type BookingFilter struct {
+	ShowsAfter  *time.Time
+	ShowsBefore *time.Time // inclusive
+}
+
+type BookingRepository interface {
+	// Create stores the booking with its line items
+	Create(booking *models.Booking) error
+	Get(id uint) (models.Booking, error)
+	// Lock is Get that also locks the booking until the transaction ends, so
+	// a payment result, a cancellation and the expiry reaper cannot all act on it
+	Lock(id uint) (models.Booking, error)
+	// GetDetailed returns the booking with its seats, line items and refunds
+	GetDetailed(id uint) (models.Booking, error)
+	GetByPaymentIntent(intentID string) (models.Booking, error)
+	// ListActiveByShow returns the show's bookings that hold their seats, with the seats
+	ListActiveByShow(showID uint) ([]models.Booking, error)
+	// ListForUser returns one page of a user's bookings with their seats,
+	// newest show first, and how many there are in total
+	ListForUser(userID uint, filter BookingFilter, page int, pageSize int) ([]models.Booking, int64, error)
+	Update(id uint, updates map[string]interface{}) error
+	// LinkSeats makes the seats part of the booking, ErrConflict if one is in another active booking
+	LinkSeats(bookingID uint, seatIDs []uint) error
+	// MoveSeat links the booking, and the line item for the seat, to another
+	// seat in place of one it has. The old link is kept as history.
+	MoveSeat(bookingID uint, fromSeatID uint, toSeatID uint) error
+	// ReleaseSeats puts the booking's seats back on sale, keeping the links as history
+	ReleaseSeats(bookingID uint) error
+	// ExpireUnpaid expires bookings whose payment window closed before now and releases their seats
+	ExpireUnpaid(now time.Time) (int64, error)
+}
+
+type RefundRepository interface {
+	// Create and Save store the refund and mirror its status on the booking
+	Create(refund *models.Refund) error
+	Save(refund *models.Refund) error
+	GetByProviderID(providerRefundID string) (models.Refund, error)
+}
+
+// Store hands out repositories that share one database connection or transaction
+type Store interface {
+	Movies() MovieRepository
+	Theaters() TheaterRepository
+	Shows() ShowRepository
+	Schedules() ScheduleRepository
+	Seats() SeatRepository
+	Users() UserRepository
+	Bookings() BookingRepository
+	Refunds() RefundRepository
+	// Transaction runs fn with a Store whose repositories all work in one
+	// transaction, committed if fn returns nil and rolled back otherwise
+	Transaction(fn func(Store) error) error
+}
+
+// gormStore is the Store backed by gorm
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewStore returns a Store backed by db
+func NewStore(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+func (s *gormStore) Movies() MovieRepository       { return movieRepository{s.db} }
+func (s *gormStore) Theaters() TheaterRepository   { return theaterRepository{s.db} }
+func (s *gormStore) Shows() ShowRepository         { return showRepository{s.db} }
+func (s *gormStore) Schedules() ScheduleRepository { return scheduleRepository{s.db} }
+func (s *gormStore) Seats() SeatRepository         { return seatRepository{s.db} }
+func (s *gormStore) Users() UserRepository         { return userRepository{s.db} }
+func (s *gormStore) Bookings() BookingRepository   { return bookingRepository{s.db} }
+func (s *gormStore) Refunds() RefundRepository     { return refundRepository{s.db} }
+
+func (s *gormStore) Transaction(fn func(Store) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&gormStore{db: tx})
+	})
+}
+
+// translate maps gorm errors onto the repository's own
+func translate(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrConflict
+	default:
+		return err
+	}
+}
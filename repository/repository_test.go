@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"ETE3/config"
+	"ETE3/db"
+	"ETE3/migrations"
+	"ETE3/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore(t *testing.T) Store {
+	testDB, err := db.Open(config.DatabaseConfig{Driver: db.DriverSQLiteMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.Up(testDB); err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(testDB)
+}
+
+// Test Seats().Update refuses to overwrite a seat changed since it was read
+func TestSeatUpdateRejectsStaleVersion(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	assert.NoError(t, store.Seats().CreateMany([]models.Seat{{ShowID: 1, Row: "A", Number: 1, Status: models.Available}}))
+
+	seats, _ := store.Seats().ListByShow(1)
+	first, second := seats[0], seats[0]
+
+	err := store.Seats().Update(first, map[string]interface{}{"status": models.Held, "held_by": 1})
+	assert.NoError(t, err)
+
+	err = store.Seats().Update(second, map[string]interface{}{"status": models.Held, "held_by": 2})
+	assert.ErrorIs(t, err, ErrConflict)
+
+	seats, _ = store.Seats().ListByShow(1)
+	assert.Equal(t, uint(1), seats[0].HeldBy)
+	assert.Equal(t, uint(1), seats[0].Version)
+}
+
+// Test Transaction rolls back every repository when fn fails
+func TestTransactionRollsBack(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	err := store.Transaction(func(tx Store) error {
+		if err := tx.Movies().Create(&models.Movie{Title: "Kept?"}); err != nil {
+			return err
+		}
+		_, err := tx.Movies().Get(42)
+		return err
+	})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	movies, err := store.Movies().List()
+	assert.NoError(t, err)
+	assert.Empty(t, movies)
+}
+
+// Test ListForUser filters by show time and pages newest show first
+func TestBookingListForUser(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	now := time.Date(2025, time.April, 7, 9, 0, 0, 0, time.UTC)
+	for i, offset := range []time.Duration{-time.Hour, time.Hour, 2 * time.Hour} {
+		show := models.Show{MovieID: 1, Time: now.Add(offset)}
+		store.Shows().Create(&show)
+		store.Bookings().Create(&models.Booking{UserID: 1, ShowID: show.ID, Status: models.BookingConfirmed})
+		if i == 0 {
+			store.Bookings().Create(&models.Booking{UserID: 2, ShowID: show.ID, Status: models.BookingConfirmed})
+		}
+	}
+
+	bookings, total, err := store.Bookings().ListForUser(1, BookingFilter{ShowsAfter: &now}, 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, bookings, 1)
+	assert.Equal(t, uint(3), bookings[0].ShowID)
+
+	bookings, total, err = store.Bookings().ListForUser(1, BookingFilter{ShowsBefore: &now}, 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, uint(1), bookings[0].ShowID)
+
+	// Show times are stored in UTC, a clock in another zone splits them the same way
+	local := now.In(time.FixedZone("EDT", -4*60*60))
+	_, total, err = store.Bookings().ListForUser(1, BookingFilter{ShowsAfter: &local}, 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	_, total, err = store.Bookings().ListForUser(1, BookingFilter{ShowsBefore: &local}, 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+
+	_, err = store.Bookings().GetByPaymentIntent("pi_missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// Test theaters are found by city whatever its case
+func TestTheaterListByCity(t *testing.T) {
+	t.Parallel()
+	store := newTestStore(t)
+
+	assert.NoError(t, store.Theaters().Create(&models.Theater{Name: "Central", City: "Pune"}))
+	assert.NoError(t, store.Theaters().Create(&models.Theater{Name: "Harbour", City: "Mumbai"}))
+
+	theaters, err := store.Theaters().List("pune")
+	assert.NoError(t, err)
+	if assert.Len(t, theaters, 1) {
+		assert.Equal(t, "Central", theaters[0].Name)
+	}
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"ETE3/models"
+
+	"gorm.io/gorm"
+)
+
+type scheduleRepository struct {
+	db *gorm.DB
+}
+
+func (r scheduleRepository) Create(schedule *models.ShowSchedule) error {
+	return translate(r.db.Create(schedule).Error)
+}
+
+func (r scheduleRepository) Get(id uint) (models.ShowSchedule, error) {
+	var schedule models.ShowSchedule
+	err := r.db.First(&schedule, id).Error
+	return schedule, translate(err)
+}
+
+func (r scheduleRepository) Update(schedule *models.ShowSchedule) error {
+	return translate(r.db.Save(schedule).Error)
+}
+
+func (r scheduleRepository) Delete(id uint) error {
+	return translate(r.db.Delete(&models.ShowSchedule{}, id).Error)
+}
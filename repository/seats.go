@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"time"
+
+	"ETE3/models"
+
+	"gorm.io/gorm"
+)
+
+type seatRepository struct {
+	db *gorm.DB
+}
+
+func (r seatRepository) CreateMany(seats []models.Seat) error {
+	if len(seats) == 0 {
+		return nil
+	}
+	return translate(r.db.Create(&seats).Error)
+}
+
+func (r seatRepository) ListByShow(showID uint) ([]models.Seat, error) {
+	var seats []models.Seat
+	err := r.db.Where("show_id = ?", showID).Find(&seats).Error
+	return seats, translate(err)
+}
+
+func (r seatRepository) ListAvailable(showID uint) ([]models.Seat, error) {
+	var seats []models.Seat
+	err := r.db.Where("show_id = ? AND status = ?", showID, models.Available).Find(&seats).Error
+	return seats, translate(err)
+}
+
+// Update bumps the version along with the change, so that anyone else holding
+// the old copy of the seat fails
+func (r seatRepository) Update(seat models.Seat, updates map[string]interface{}) error {
+	updates["version"] = gorm.Expr("version + 1")
+	result := r.db.Model(&models.Seat{}).Where("id = ? AND version = ?", seat.ID, seat.Version).Updates(updates)
+	if result.Error != nil {
+		return translate(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r seatRepository) ReleaseExpiredHolds(now time.Time) (int64, error) {
+	result := r.db.Model(&models.Seat{}).
+		Where("status = ? AND hold_expires_at <= ?", models.Held, now.UTC()).
+		Updates(map[string]interface{}{
+			"status":          models.Available,
+			"held_by":         0,
+			"hold_expires_at": nil,
+			"version":         gorm.Expr("version + 1"),
+		})
+	return result.RowsAffected, translate(result.Error)
+}
+
+func (r seatRepository) CountTaken(showIDs []uint, now time.Time) (int64, error) {
+	if len(showIDs) == 0 {
+		return 0, nil
+	}
+	var count int64
+	err := r.db.Model(&models.Seat{}).
+		Where("show_id IN ? AND (status = ? OR (status = ? AND hold_expires_at > ?))", showIDs, models.Booked, models.Held, now.UTC()).
+		Count(&count).Error
+	return count, translate(err)
+}
+
+func (r seatRepository) DeleteByShow(showID uint) error {
+	return translate(r.db.Where("show_id = ?", showID).Delete(&models.Seat{}).Error)
+}
+
+func (r seatRepository) CountAvailable(showIDs []uint, now time.Time) (map[uint]int64, error) {
+	counts := make(map[uint]int64, len(showIDs))
+	if len(showIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		ShowID uint
+		Count  int64
+	}
+	err := r.db.Model(&models.Seat{}).Select("show_id, COUNT(*) AS count").
+		Where("show_id IN ? AND (status = ? OR (status = ? AND hold_expires_at <= ?))", showIDs, models.Available, models.Held, now.UTC()).
+		Group("show_id").Scan(&rows).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+	for _, row := range rows {
+		counts[row.ShowID] = row.Count
+	}
+	return counts, nil
+}
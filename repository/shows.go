@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"time"
+
+	"ETE3/models"
+
+	"gorm.io/gorm"
+)
+
+// showRepository compares times in UTC, the zone show times are stored in,
+// since SQLite compares them as text
+type showRepository struct {
+	db *gorm.DB
+}
+
+func (r showRepository) Create(show *models.Show) error {
+	return translate(r.db.Create(show).Error)
+}
+
+func (r showRepository) Get(id uint) (models.Show, error) {
+	var show models.Show
+	err := r.db.Preload("Prices").First(&show, id).Error
+	return show, translate(err)
+}
+
+func (r showRepository) ListByMovie(movieID uint, after time.Time) ([]models.Show, error) {
+	var shows []models.Show
+	err := r.db.Preload("Prices").
+		Where("movie_id = ? AND time > ? AND cancelled_at IS NULL", movieID, after.UTC()).
+		Order("time").Find(&shows).Error
+	return shows, translate(err)
+}
+
+func (r showRepository) Search(filter ShowFilter) ([]models.Show, error) {
+	query := r.db.Preload("Prices").
+		Joins("JOIN screens ON screens.id = shows.screen_id AND screens.deleted_at IS NULL").
+		Joins("JOIN theaters ON theaters.id = screens.theater_id AND theaters.deleted_at IS NULL").
+		Joins("JOIN movies ON movies.id = shows.movie_id AND movies.deleted_at IS NULL").
+		Where("shows.time > ? AND shows.time < ? AND shows.cancelled_at IS NULL", filter.After.UTC(), filter.Before.UTC())
+	if filter.City != "" {
+		query = query.Where("LOWER(theaters.city) = LOWER(?)", filter.City)
+	}
+	if filter.TheaterID != 0 {
+		query = query.Where("theaters.id = ?", filter.TheaterID)
+	}
+	if filter.MovieID != 0 {
+		query = query.Where("movies.id = ?", filter.MovieID)
+	}
+	if filter.Language != "" {
+		query = query.Where("LOWER(movies.language) = LOWER(?)", filter.Language)
+	}
+	if filter.Format != "" {
+		query = query.Where("shows.format = ?", filter.Format)
+	}
+
+	var shows []models.Show
+	err := query.Order("shows.time, shows.id").Find(&shows).Error
+	return shows, translate(err)
+}
+
+func (r showRepository) ListByIDs(ids []uint) ([]models.Show, error) {
+	var shows []models.Show
+	err := r.db.Where("id IN ?", ids).Find(&shows).Error
+	return shows, translate(err)
+}
+
+func (r showRepository) CountByMovieAfter(movieID uint, after time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Show{}).Where("movie_id = ? AND time > ? AND cancelled_at IS NULL", movieID, after.UTC()).Count(&count).Error
+	return count, translate(err)
+}
+
+func (r showRepository) ListOverlapping(screenID uint, start time.Time, end time.Time) ([]models.Show, error) {
+	var shows []models.Show
+	err := r.db.Where("screen_id = ? AND time < ? AND ends_at > ? AND cancelled_at IS NULL", screenID, end.UTC(), start.UTC()).
+		Order("time").Find(&shows).Error
+	return shows, translate(err)
+}
+
+func (r showRepository) ListBySchedule(scheduleID uint, after time.Time) ([]models.Show, error) {
+	var shows []models.Show
+	err := r.db.Where("schedule_id = ? AND time > ? AND cancelled_at IS NULL", scheduleID, after.UTC()).Order("time").Find(&shows).Error
+	return shows, translate(err)
+}
+
+func (r showRepository) DeleteMany(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := r.db.Where("show_id IN ?", ids).Delete(&models.Seat{}).Error; err != nil {
+		return translate(err)
+	}
+	if err := r.db.Where("show_id IN ?", ids).Delete(&models.ShowPrice{}).Error; err != nil {
+		return translate(err)
+	}
+	return translate(r.db.Delete(&models.Show{}, ids).Error)
+}
+
+func (r showRepository) Update(id uint, updates map[string]interface{}) error {
+	return translate(r.db.Model(&models.Show{}).Where("id = ?", id).Updates(updates).Error)
+}
+
+func (r showRepository) ReplacePrices(showID uint, prices []models.ShowPrice) error {
+	if err := r.db.Where("show_id = ?", showID).Delete(&models.ShowPrice{}).Error; err != nil {
+		return translate(err)
+	}
+	if len(prices) == 0 {
+		return nil
+	}
+	for i := range prices {
+		prices[i].ID = 0
+		prices[i].ShowID = showID
+	}
+	return translate(r.db.Create(&prices).Error)
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"ETE3/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type theaterRepository struct {
+	db *gorm.DB
+}
+
+func (r theaterRepository) Create(theater *models.Theater) error {
+	return translate(r.db.Create(theater).Error)
+}
+
+func (r theaterRepository) Get(id uint) (models.Theater, error) {
+	var theater models.Theater
+	err := r.db.Preload("Screens").First(&theater, id).Error
+	return theater, translate(err)
+}
+
+func (r theaterRepository) List(city string) ([]models.Theater, error) {
+	query := r.db
+	if city != "" {
+		query = query.Where("LOWER(city) = LOWER(?)", city)
+	}
+
+	var theaters []models.Theater
+	err := query.Find(&theaters).Error
+	return theaters, translate(err)
+}
+
+func (r theaterRepository) ListByIDs(ids []uint) ([]models.Theater, error) {
+	var theaters []models.Theater
+	err := r.db.Where("id IN ?", ids).Find(&theaters).Error
+	return theaters, translate(err)
+}
+
+func (r theaterRepository) CreateScreen(screen *models.Screen) error {
+	return translate(r.db.Create(screen).Error)
+}
+
+func (r theaterRepository) GetScreen(id uint) (models.Screen, error) {
+	var screen models.Screen
+	err := r.db.First(&screen, id).Error
+	return screen, translate(err)
+}
+
+func (r theaterRepository) LockScreen(id uint) (models.Screen, error) {
+	var screen models.Screen
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&screen, id).Error
+	return screen, translate(err)
+}
+
+func (r theaterRepository) ListScreensByIDs(ids []uint) ([]models.Screen, error) {
+	var screens []models.Screen
+	err := r.db.Where("id IN ?", ids).Find(&screens).Error
+	return screens, translate(err)
+}
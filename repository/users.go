@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"ETE3/models"
+
+	"gorm.io/gorm"
+)
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+func (r userRepository) Create(user *models.User) error {
+	return translate(r.db.Create(user).Error)
+}
+
+func (r userRepository) Get(id uint) (models.User, error) {
+	var user models.User
+	err := r.db.First(&user, id).Error
+	return user, translate(err)
+}
+
+func (r userRepository) GetByEmail(email string) (models.User, error) {
+	var user models.User
+	err := r.db.Where("email = ?", email).First(&user).Error
+	return user, translate(err)
+}
+
+func (r userRepository) SetRole(id uint, role string) error {
+	return translate(r.db.Model(&models.User{}).Where("id = ?", id).Update("role", role).Error)
+}
@@ -0,0 +1,321 @@
+package seed
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ETE3/models"
+	"ETE3/money"
+	"ETE3/repository"
+	"ETE3/service"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+//go:embed default.yaml
+var defaultFixture []byte
+
+// Fixture describes the catalog to seed: theaters, movies and their show schedules
+type Fixture struct {
+	Timezone  string     `json:"timezone" yaml:"timezone"` // show times are local to this zone, UTC by default
+	Currency  string     `json:"currency" yaml:"currency"` // ISO 4217 code of every price, must be set before Run
+	Theaters  []Theater  `json:"theaters" yaml:"theaters"`
+	Movies    []Movie    `json:"movies" yaml:"movies"`
+	Schedules []Schedule `json:"schedules" yaml:"schedules"`
+}
+
+type Theater struct {
+	Name    string   `json:"name" yaml:"name"`
+	City    string   `json:"city" yaml:"city"`
+	Address string   `json:"address" yaml:"address"`
+	Screens []Screen `json:"screens" yaml:"screens"`
+}
+
+// Screen is either a plain grid given by Rows and SeatsPerRow, or a full Layout
+type Screen struct {
+	Name        string             `json:"name" yaml:"name"`
+	Rows        string             `json:"rows" yaml:"rows"` // one letter per row, e.g. "ABCDEFGHIJ"
+	SeatsPerRow int                `json:"seats_per_row" yaml:"seats_per_row"`
+	Layout      *models.SeatLayout `json:"layout" yaml:"layout"`
+}
+
+func (s Screen) layout() models.SeatLayout {
+	if s.Layout != nil {
+		return *s.Layout
+	}
+	return models.GridLayout(s.Rows, s.SeatsPerRow)
+}
+
+type Movie struct {
+	Title    string `json:"title" yaml:"title"`
+	Duration int    `json:"duration" yaml:"duration"`
+	Photo    string `json:"photo" yaml:"photo"`
+}
+
+// Schedule creates a show at each of Times on each of Days consecutive days.
+// The first day is StartDate if set, otherwise today plus DaysFromToday.
+type Schedule struct {
+	Movie         string             `json:"movie" yaml:"movie"`
+	Theater       string             `json:"theater" yaml:"theater"`
+	Screen        string             `json:"screen" yaml:"screen"`
+	StartDate     string             `json:"start_date" yaml:"start_date"` // YYYY-MM-DD
+	DaysFromToday int                `json:"days_from_today" yaml:"days_from_today"`
+	Days          int                `json:"days" yaml:"days"`     // defaults to 1
+	Times         []string           `json:"times" yaml:"times"`   // HH:MM
+	Price         float64            `json:"price" yaml:"price"`   // for categories not listed in Prices
+	Prices        map[string]float64 `json:"prices" yaml:"prices"` // seat category to price
+}
+
+// Result counts what a seeding run actually inserted
+type Result struct {
+	MoviesCreated int
+	ShowsCreated  int
+	SeatsCreated  int
+	ShowsSkipped  int // their screen was busy with another show
+}
+
+// Default returns the fixture bundled with the binary
+func Default() (*Fixture, error) {
+	return parse(defaultFixture, ".yaml")
+}
+
+// LoadFile reads a JSON or YAML fixture, picked by extension
+func LoadFile(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading fixture: %w", err)
+	}
+	return parse(data, filepath.Ext(path))
+}
+
+func parse(data []byte, ext string) (*Fixture, error) {
+	var fixture Fixture
+	var err error
+
+	switch strings.ToLower(ext) {
+	case ".json":
+		err = json.Unmarshal(data, &fixture)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &fixture)
+	default:
+		return nil, fmt.Errorf("unsupported fixture type %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing fixture: %w", err)
+	}
+
+	if err := fixture.Validate(); err != nil {
+		return nil, err
+	}
+	return &fixture, nil
+}
+
+// Validate checks that every schedule points at a known movie and screen
+func (f *Fixture) Validate() error {
+	var problems []string
+
+	if _, err := f.location(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if f.Currency != "" && !money.ValidCurrency(f.Currency) {
+		problems = append(problems, fmt.Sprintf("currency %q is not an ISO 4217 code", f.Currency))
+	}
+
+	screens := make(map[string]bool)
+	for _, theater := range f.Theaters {
+		if theater.Name == "" || theater.City == "" {
+			problems = append(problems, fmt.Sprintf("theater %q needs a name and a city", theater.Name))
+		}
+		for _, screen := range theater.Screens {
+			if screen.Name == "" {
+				problems = append(problems, fmt.Sprintf("theater %q has a screen without a name", theater.Name))
+			}
+			if err := screen.layout().Validate(); err != nil {
+				problems = append(problems, fmt.Sprintf("screen %q in %q: %v", screen.Name, theater.Name, err))
+			}
+			screens[theater.Name+"/"+screen.Name] = true
+		}
+	}
+
+	movies := make(map[string]bool)
+	for _, movie := range f.Movies {
+		if movie.Title == "" {
+			problems = append(problems, "every movie needs a title")
+		}
+		movies[movie.Title] = true
+	}
+
+	for i, schedule := range f.Schedules {
+		if !movies[schedule.Movie] {
+			problems = append(problems, fmt.Sprintf("schedule %d: unknown movie %q", i+1, schedule.Movie))
+		}
+		if !screens[schedule.Theater+"/"+schedule.Screen] {
+			problems = append(problems, fmt.Sprintf("schedule %d: unknown screen %q in theater %q", i+1, schedule.Screen, schedule.Theater))
+		}
+		if schedule.StartDate != "" {
+			if _, err := time.Parse("2006-01-02", schedule.StartDate); err != nil {
+				problems = append(problems, fmt.Sprintf("schedule %d: start_date must be YYYY-MM-DD", i+1))
+			}
+		}
+		if len(schedule.Times) == 0 {
+			problems = append(problems, fmt.Sprintf("schedule %d: at least one time is required", i+1))
+		}
+		for category := range schedule.Prices {
+			if !models.IsSeatCategory(category) {
+				problems = append(problems, fmt.Sprintf("schedule %d: unknown seat category %q", i+1, category))
+			}
+		}
+		for _, clock := range schedule.Times {
+			if _, err := time.Parse("15:04", clock); err != nil {
+				problems = append(problems, fmt.Sprintf("schedule %d: time %q must be HH:MM", i+1, clock))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid fixture: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (f *Fixture) location() (*time.Location, error) {
+	if f.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(f.Timezone)
+}
+
+// showTimes expands a schedule into concrete show times
+func (s Schedule) showTimes(today time.Time, loc *time.Location) []time.Time {
+	start := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, s.DaysFromToday)
+	if s.StartDate != "" {
+		start, _ = time.ParseInLocation("2006-01-02", s.StartDate, loc)
+	}
+
+	days := s.Days
+	if days < 1 {
+		days = 1
+	}
+
+	var times []time.Time
+	for day := 0; day < days; day++ {
+		date := start.AddDate(0, 0, day)
+		for _, clock := range s.Times {
+			at, _ := time.Parse("15:04", clock)
+			times = append(times, time.Date(date.Year(), date.Month(), date.Day(), at.Hour(), at.Minute(), 0, 0, loc))
+		}
+	}
+	return times
+}
+
+// Run inserts whatever part of the fixture is missing from the database.
+// Theaters are matched by name and city, screens by theater and name, movies
+// by title and shows by screen, movie and start time, so running it again
+// with the same fixture and day changes nothing. Shows are stored through
+// scheduling, which sets when they end and skips those that would overlap.
+func Run(db *gorm.DB, fixture *Fixture, today time.Time, scheduling *service.SchedulingService) (Result, error) {
+	var result Result
+
+	loc, err := fixture.location()
+	if err != nil {
+		return result, err
+	}
+	if fixture.Currency == "" {
+		return result, errors.New("fixture currency is required")
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		screens := make(map[string]models.Screen)
+		for _, theater := range fixture.Theaters {
+			existingTheater := models.Theater{Name: theater.Name, City: theater.City}
+			if err := tx.Where(existingTheater).Attrs(models.Theater{Address: theater.Address}).
+				FirstOrCreate(&existingTheater).Error; err != nil {
+				return fmt.Errorf("adding theater %s: %w", theater.Name, err)
+			}
+
+			for _, screen := range theater.Screens {
+				existingScreen := models.Screen{TheaterID: existingTheater.ID, Name: screen.Name}
+				if err := tx.Where(existingScreen).Attrs(models.Screen{Layout: screen.layout()}).
+					FirstOrCreate(&existingScreen).Error; err != nil {
+					return fmt.Errorf("adding screen %s: %w", screen.Name, err)
+				}
+				screens[theater.Name+"/"+screen.Name] = existingScreen
+			}
+		}
+
+		movies := make(map[string]models.Movie)
+		for _, movie := range fixture.Movies {
+			var existing models.Movie
+			err := tx.Where("title = ?", movie.Title).First(&existing).Error
+			if err == nil {
+				movies[movie.Title] = existing
+				continue
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			// Seeded movies get shows right away
+			created := models.Movie{Title: movie.Title, Duration: movie.Duration, Photo: movie.Photo, Status: models.MovieNowShowing}
+			if err := tx.Create(&created).Error; err != nil {
+				return fmt.Errorf("adding movie %s: %w", movie.Title, err)
+			}
+			movies[movie.Title] = created
+			result.MoviesCreated++
+		}
+
+		for _, schedule := range fixture.Schedules {
+			movie := movies[schedule.Movie]
+			screen := screens[schedule.Theater+"/"+schedule.Screen]
+			for _, at := range schedule.showTimes(today, loc) {
+				at = at.UTC()
+				var count int64
+				if err := tx.Model(&models.Show{}).
+					Where("screen_id = ? AND movie_id = ? AND time = ?", screen.ID, movie.ID, at).
+					Count(&count).Error; err != nil {
+					return err
+				}
+				if count > 0 {
+					continue
+				}
+
+				show := models.Show{
+					MovieID:  movie.ID,
+					ScreenID: screen.ID,
+					Time:     at,
+					Price:    money.FromFloat(schedule.Price, fixture.Currency),
+				}
+				for category, price := range schedule.Prices {
+					show.Prices = append(show.Prices, models.ShowPrice{
+						Category: category,
+						Price:    money.FromFloat(price, fixture.Currency),
+					})
+				}
+				conflict, err := scheduling.SeedShow(repository.NewStore(tx), &show, movie, screen)
+				if err != nil {
+					return fmt.Errorf("adding show for %s: %w", schedule.Movie, err)
+				}
+				if conflict != nil {
+					log.Printf("Skipping %s at %s on %s, show %d is on the screen then",
+						schedule.Movie, at.Format(time.RFC3339), schedule.Screen, conflict.ID)
+					result.ShowsSkipped++
+					continue
+				}
+				result.ShowsCreated++
+				result.SeatsCreated += len(screen.Layout.SeatsForShow(show.ID))
+			}
+		}
+
+		return nil
+	})
+
+	return result, err
+}
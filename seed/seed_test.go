@@ -0,0 +1,89 @@
+package seed
+
+import (
+	"ETE3/config"
+	"ETE3/db"
+	"ETE3/migrations"
+	"ETE3/models"
+	"ETE3/repository"
+	"ETE3/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// newScheduling returns a scheduling service on testDB with 15 minute buffers
+func newScheduling(t *testing.T) (*gorm.DB, *service.SchedulingService) {
+	testDB, _ := db.Open(config.DatabaseConfig{Driver: db.DriverSQLiteMemory})
+	_, err := migrations.Up(testDB)
+	assert.NoError(t, err)
+
+	scheduling := service.NewSchedulingService(repository.NewStore(testDB), "USD", nil)
+	scheduling.AdBuffer = 15 * time.Minute
+	scheduling.CleaningBuffer = 15 * time.Minute
+	return testDB, scheduling
+}
+
+// Test that seeding the same fixture twice inserts nothing the second time
+func TestRunIsIdempotent(t *testing.T) {
+	testDB, scheduling := newScheduling(t)
+
+	fixture, err := Default()
+	assert.NoError(t, err)
+	fixture.Currency = "USD"
+
+	today := time.Date(2025, time.April, 7, 8, 0, 0, 0, time.UTC)
+
+	first, err := Run(testDB, fixture, today, scheduling)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, first.MoviesCreated)
+	assert.Equal(t, 5*4*7, first.ShowsCreated)
+	assert.Equal(t, 5*4*7*150, first.SeatsCreated)
+
+	second, err := Run(testDB, fixture, today, scheduling)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{}, second)
+
+	var show models.Show
+	testDB.Order("time").First(&show)
+	assert.True(t, show.Time.Equal(time.Date(2025, time.April, 7, 10, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "12.50 USD", show.Price.String())
+	// Inception runs 148 minutes, plus the buffers
+	assert.True(t, show.EndsAt.Equal(time.Date(2025, time.April, 7, 12, 58, 0, 0, time.UTC)))
+}
+
+// Test that fixture times are stored in UTC and shows that would overlap on a
+// screen are skipped
+func TestRunSkipsOverlappingShows(t *testing.T) {
+	testDB, scheduling := newScheduling(t)
+
+	fixture := &Fixture{
+		Timezone: "Asia/Kolkata",
+		Currency: "USD",
+		Theaters: []Theater{{Name: "Test Theater", City: "Test City", Screens: []Screen{{Name: "Screen 1", Rows: "AB", SeatsPerRow: 5}}}},
+		Movies:   []Movie{{Title: "Long", Duration: 150}, {Title: "Short", Duration: 90}},
+		Schedules: []Schedule{
+			{Movie: "Long", Theater: "Test Theater", Screen: "Screen 1", Times: []string{"10:00"}, Price: 10},
+			// 12:50 is before the screen is cleaned after Long, 13:00 is not
+			{Movie: "Short", Theater: "Test Theater", Screen: "Screen 1", Times: []string{"12:50", "13:00"}, Price: 10},
+		},
+	}
+	today := time.Date(2025, time.April, 7, 0, 0, 0, 0, time.UTC)
+
+	result, err := Run(testDB, fixture, today, scheduling)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.ShowsCreated)
+	assert.Equal(t, 1, result.ShowsSkipped)
+	assert.Equal(t, 2*10, result.SeatsCreated)
+
+	var shows []models.Show
+	testDB.Order("time").Find(&shows)
+	if assert.Len(t, shows, 2) {
+		assert.Equal(t, time.UTC, shows[0].Time.Location())
+		assert.True(t, shows[0].Time.Equal(time.Date(2025, time.April, 7, 4, 30, 0, 0, time.UTC)))
+		assert.True(t, shows[0].EndsAt.Equal(time.Date(2025, time.April, 7, 7, 30, 0, 0, time.UTC)))
+		assert.True(t, shows[1].Time.Equal(time.Date(2025, time.April, 7, 7, 30, 0, 0, time.UTC)))
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"ETE3/apperror"
+	"ETE3/models"
+	"ETE3/repository"
+
+	"github.com/golang-jwt/jwt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AuthService registers users, logs them in with signed tokens and manages roles
+type AuthService struct {
+	Store  repository.Store
+	Secret []byte // signs the tokens AuthMiddleware checks
+}
+
+// NewAuthService returns an AuthService signing tokens with secret
+func NewAuthService(store repository.Store, secret []byte) *AuthService {
+	return &AuthService{Store: store, Secret: secret}
+}
+
+// Register creates a customer account. Self-registered users are always
+// customers, roles are granted by an admin.
+func (s *AuthService) Register(name string, email string, password string) (models.User, error) {
+	// Hash the password before storing it
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return models.User{}, apperror.Internal(err, "Failed to register user")
+	}
+
+	user := models.User{
+		Name:     name,
+		Email:    email,
+		Password: string(hashedPassword),
+		Role:     models.RoleCustomer,
+	}
+
+	err = s.Store.Users().Create(&user)
+	if errors.Is(err, repository.ErrConflict) {
+		return models.User{}, apperror.Conflict("email_taken", "A user with this email already exists")
+	}
+	if err != nil {
+		return models.User{}, apperror.Internal(err, "Failed to register user")
+	}
+	return user, nil
+}
+
+// Login checks the credentials and returns a token for the user
+func (s *AuthService) Login(email string, password string) (string, error) {
+	// An unknown email is reported like a wrong password
+	user, err := s.Store.Users().GetByEmail(email)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return "", apperror.Internal(err, "Failed to log in")
+	}
+
+	if err != nil || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return "", apperror.Unauthorized("invalid_credentials", "Invalid credentials")
+	}
+
+	token, err := GenerateToken(s.Secret, user.ID, user.Email, user.Role)
+	if err != nil {
+		return "", apperror.Internal(err, "Failed to log in")
+	}
+	return token, nil
+}
+
+// SetRole changes a user's role
+func (s *AuthService) SetRole(userID uint, role string) (models.User, error) {
+	user, err := s.Store.Users().Get(userID)
+	if err != nil {
+		return models.User{}, notFoundOr(err, "user_not_found", "User not found")
+	}
+
+	if err := s.Store.Users().SetRole(user.ID, role); err != nil {
+		return models.User{}, apperror.Internal(err, "Failed to update role")
+	}
+	user.Role = role
+	return user, nil
+}
+
+// BootstrapAdmin makes sure an admin account exists for the given credentials.
+// An existing user with that email is promoted, otherwise a new one is created.
+func (s *AuthService) BootstrapAdmin(email string, password string) error {
+	if email == "" || password == "" {
+		return errors.New("admin email and password are required")
+	}
+
+	user, err := s.Store.Users().GetByEmail(email)
+	if err == nil {
+		if user.Role == models.RoleAdmin {
+			return nil
+		}
+		log.Printf("✅ Promoting %s to admin", email)
+		return s.Store.Users().SetRole(user.ID, models.RoleAdmin)
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ Creating admin %s", email)
+	return s.Store.Users().Create(&models.User{
+		Name:     "Administrator",
+		Email:    email,
+		Password: string(hashedPassword),
+		Role:     models.RoleAdmin,
+	})
+}
+
+func GenerateToken(secret []byte, userID uint, email string, role string) (string, error) {
+	claims := jwt.MapClaims{
+		"id":    userID,
+		"email": email,
+		"role":  role,
+		"exp":   time.Now().Add(time.Hour * 24).Unix(), // Token valid for 24 hours
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(secret)
+}
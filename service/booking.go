@@ -0,0 +1,616 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"ETE3/apperror"
+	"ETE3/models"
+	"ETE3/money"
+	"ETE3/payments"
+	"ETE3/refunds"
+	"ETE3/repository"
+)
+
+// BookingService holds seats, turns holds into bookings and takes them
+// through payment and cancellation
+type BookingService struct {
+	Store    repository.Store
+	Payments payments.Provider
+	Refunds  *refunds.Service
+
+	// Now returns the current time, time.Now if nil. Tests replace it to control expiry.
+	Now func() time.Time
+
+	HoldTTL            time.Duration // how long a seat stays held
+	PaymentTTL         time.Duration // how long a booking waits to be paid before its seats are released
+	CancellationCutoff time.Duration // how long before the show bookings can no longer be cancelled
+	TaxRateBPS         int64         // tax on every seat in basis points, 1800 is 18%
+}
+
+// NewBookingService returns a BookingService with the default hold,
+// payment and cancellation windows
+func NewBookingService(store repository.Store, provider payments.Provider, refunder *refunds.Service) *BookingService {
+	return &BookingService{
+		Store:              store,
+		Payments:           provider,
+		Refunds:            refunder,
+		HoldTTL:            10 * time.Minute,
+		PaymentTTL:         15 * time.Minute,
+		CancellationCutoff: 2 * time.Hour,
+	}
+}
+
+func (s *BookingService) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+	return s.Now()
+}
+
+// Booked is a new booking with the payment the customer has to complete
+type Booked struct {
+	Booking models.Booking
+	Show    models.Show
+	Intent  payments.Intent
+}
+
+// BookingDetails is a booking with the show and movie it is for
+type BookingDetails struct {
+	Booking models.Booking
+	Show    models.Show
+	Movie   models.Movie
+}
+
+// Hold places a temporary hold on the labelled seats, e.g. "A1", for the user
+// and returns when it expires
+func (s *BookingService) Hold(userID uint, showID uint, labels []string) (time.Time, error) {
+	now := s.now()
+	// Stored in UTC like every time the database compares
+	expiresAt := now.Add(s.HoldTTL).UTC()
+
+	err := s.Store.Transaction(func(tx repository.Store) error {
+		if _, err := openShow(tx, showID); err != nil {
+			return err
+		}
+
+		seats, err := seatsByLabel(tx, showID, labels)
+		if err != nil {
+			return err
+		}
+
+		for i, seat := range seats {
+			// A seat can be held if it is free, already ours, or its previous hold has lapsed
+			holdable := seat.Status == models.Available ||
+				(seat.Status == models.Held && (seat.HeldBy == userID || holdExpired(seat, now)))
+			if !holdable {
+				return apperror.Conflict("seat_unavailable", fmt.Sprintf("Seat %s is not available", labels[i]))
+			}
+
+			err := tx.Seats().Update(seat, map[string]interface{}{
+				"status":          models.Held,
+				"held_by":         userID,
+				"hold_expires_at": expiresAt,
+			})
+			if errors.Is(err, repository.ErrConflict) {
+				return apperror.Conflict("seat_taken", fmt.Sprintf("Seat %s was just taken", labels[i]))
+			}
+			if err != nil {
+				return apperror.Internal(err, "Failed to hold seats")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, internalOr(err, "Failed to hold seats")
+	}
+	return expiresAt, nil
+}
+
+// Book turns seats the user holds into a booking awaiting payment, priced by
+// seat category with tax, and starts the payment with the provider
+func (s *BookingService) Book(ctx context.Context, userID uint, showID uint, labels []string) (Booked, error) {
+	var booked Booked
+	err := s.Store.Transaction(func(tx repository.Store) error {
+		show, err := openShow(tx, showID)
+		if err != nil {
+			return err
+		}
+
+		seats, err := seatsByLabel(tx, showID, labels)
+		if err != nil {
+			return err
+		}
+
+		// Only seats the caller is currently holding can be booked
+		now := s.now()
+		for i, seat := range seats {
+			if seat.Status != models.Held || seat.HeldBy != userID {
+				return apperror.Conflict("seat_not_held", fmt.Sprintf("Seat %s is not held by you", labels[i]))
+			}
+			if holdExpired(seat, now) {
+				return apperror.Conflict("hold_expired", fmt.Sprintf("Hold on seat %s has expired", labels[i]))
+			}
+		}
+
+		// Update each seat, failing if a concurrent request got to it first
+		seatIDs := make([]uint, 0, len(seats))
+		for i, seat := range seats {
+			err := tx.Seats().Update(seat, map[string]interface{}{
+				"status":          models.Booked,
+				"held_by":         0,
+				"hold_expires_at": nil,
+			})
+			if errors.Is(err, repository.ErrConflict) {
+				return apperror.Conflict("seat_taken", fmt.Sprintf("Seat %s was just taken", labels[i]))
+			}
+			if err != nil {
+				return apperror.Internal(err, "Failed to update seat status")
+			}
+			seatIDs = append(seatIDs, seat.ID)
+		}
+
+		// Price every seat by its category and add tax
+		totalPrice := money.Zero(show.Price.Currency)
+		lineItems := make([]models.BookingLineItem, 0, len(seats))
+		for _, seat := range seats {
+			unitPrice := show.PriceFor(seat.Type)
+			lineItem, err := models.NewLineItem(seat, unitPrice, money.Zero(unitPrice.Currency), s.TaxRateBPS)
+			if err == nil {
+				totalPrice, err = totalPrice.Add(lineItem.Total)
+			}
+			if err != nil {
+				return apperror.Internal(err, "Show prices use more than one currency")
+			}
+			lineItems = append(lineItems, lineItem)
+		}
+
+		// The booking holds on to its seats until paid for or expired
+		paymentExpiresAt := now.Add(s.PaymentTTL).UTC()
+		booking := models.Booking{
+			UserID:           userID,
+			ShowID:           showID,
+			Status:           models.BookingPendingPayment,
+			TotalPrice:       totalPrice,
+			LineItems:        lineItems,
+			PaymentExpiresAt: &paymentExpiresAt,
+		}
+		if err := tx.Bookings().Create(&booking); err != nil {
+			return apperror.Internal(err, "Failed to create booking")
+		}
+
+		// The unique index on active links is the last line of defence
+		// against a seat ending up in two live bookings
+		for i, seatID := range seatIDs {
+			err := tx.Bookings().LinkSeats(booking.ID, []uint{seatID})
+			if errors.Is(err, repository.ErrConflict) {
+				return apperror.Conflict("seat_taken", fmt.Sprintf("Seat %s is already booked", labels[i]))
+			}
+			if err != nil {
+				return apperror.Internal(err, "Failed to associate seats with booking")
+			}
+		}
+
+		booked = Booked{Booking: booking, Show: show}
+		return nil
+	})
+	if err != nil {
+		return Booked{}, internalOr(err, "Failed to complete booking")
+	}
+
+	// Ask the payment provider to collect the total. Talk to it outside of any
+	// transaction, it can be slow and the seats are already ours.
+	intent, err := s.Payments.CreateIntent(ctx, booked.Booking.TotalPrice, fmt.Sprintf("booking-%d", booked.Booking.ID))
+	if err != nil {
+		// Without a payment the booking cannot go anywhere, give the seats back
+		if _, settleErr := s.settle(booked.Booking.ID, models.BookingFailed); settleErr != nil {
+			log.Printf("❌ Error failing booking %d: %v", booked.Booking.ID, settleErr)
+		}
+		return Booked{}, apperror.New(apperror.KindUpstream, "payment_provider_error", "Failed to start payment").Wrap(err)
+	}
+
+	if err := s.Store.Bookings().Update(booked.Booking.ID, map[string]interface{}{"payment_intent_id": intent.ID}); err != nil {
+		return Booked{}, apperror.Internal(err, "Failed to create booking")
+	}
+	booked.Booking.PaymentIntentID = intent.ID
+	booked.Intent = intent
+	return booked, nil
+}
+
+// ConfirmPayment captures the payment of one of the user's pending bookings.
+// A declined payment fails the booking and is reported as payment_declined.
+func (s *BookingService) ConfirmPayment(ctx context.Context, userID uint, bookingID uint) (models.Booking, error) {
+	booking, err := s.Store.Bookings().Get(bookingID)
+	if err != nil {
+		return models.Booking{}, notFoundOr(err, "booking_not_found", "Booking not found")
+	}
+	if booking.UserID != userID {
+		return models.Booking{}, apperror.Forbidden("not_booking_owner", "You can only pay for your own bookings")
+	}
+	if booking.Status != models.BookingPendingPayment {
+		return models.Booking{}, apperror.Conflict("booking_not_payable", "Booking is not awaiting payment, it is "+booking.Status)
+	}
+
+	// Too late, give the seats back to everyone else
+	if booking.PaymentExpiresAt != nil && !booking.PaymentExpiresAt.After(s.now()) {
+		expired, err := s.settle(booking.ID, models.BookingExpired)
+		if err != nil && !errors.Is(err, errBookingNotPayable) {
+			return models.Booking{}, apperror.Internal(err, "Failed to update booking")
+		}
+		if expired.Status == models.BookingExpired {
+			s.voidPayment(ctx, expired)
+		}
+		return models.Booking{}, apperror.Conflict("payment_expired", "Payment window has expired")
+	}
+
+	// Talk to the provider outside of any transaction, it can be slow
+	_, captureErr := s.Payments.Capture(ctx, booking.PaymentIntentID)
+	if captureErr != nil && !errors.Is(captureErr, payments.ErrDeclined) {
+		return models.Booking{}, apperror.New(apperror.KindUpstream, "payment_provider_error", "Failed to capture payment").Wrap(captureErr)
+	}
+
+	status := models.BookingConfirmed
+	if captureErr != nil {
+		status = models.BookingFailed
+	}
+
+	booking, err = s.settle(booking.ID, status)
+	if errors.Is(err, errBookingNotPayable) {
+		// It expired or was cancelled while the money was being taken
+		if status == models.BookingConfirmed && booking.Status != models.BookingConfirmed {
+			s.reversePayment(ctx, booking.ID)
+			return models.Booking{}, apperror.Conflict("booking_not_payable", "Booking is no longer awaiting payment, it is "+booking.Status+". The payment will be refunded in full")
+		}
+		return models.Booking{}, apperror.Conflict("booking_not_payable", "Booking is not awaiting payment, it is "+booking.Status)
+	}
+	if err != nil {
+		return models.Booking{}, apperror.Internal(err, "Failed to update booking")
+	}
+
+	if status == models.BookingFailed {
+		return booking, apperror.New(apperror.KindPaymentRequired, "payment_declined", "Payment was declined").
+			With("booking_id", booking.ID).
+			With("status", booking.Status)
+	}
+	return booking, nil
+}
+
+// PaymentEventResult says what a webhook event changed
+type PaymentEventResult struct {
+	Ignored bool            // the event was not for us or had already been applied
+	Booking *models.Booking // the booking a payment event settled
+	// The refund a refund event settled, or with Booking the refund of a
+	// payment that went through after the booking expired or was cancelled
+	Refund *models.Refund
+}
+
+// HandlePaymentEvent verifies a webhook from the payment provider and applies it
+func (s *BookingService) HandlePaymentEvent(payload []byte, signature string) (PaymentEventResult, error) {
+	event, err := s.Payments.VerifyWebhook(payload, signature)
+	if err != nil {
+		return PaymentEventResult{}, apperror.Validation("invalid_webhook", "Invalid webhook: "+err.Error())
+	}
+
+	var status string
+	switch event.Type {
+	case payments.EventPaymentSucceeded:
+		status = models.BookingConfirmed
+	case payments.EventPaymentFailed:
+		status = models.BookingFailed
+	case payments.EventRefundSucceeded, payments.EventRefundFailed:
+		return s.settleRefund(event)
+	default:
+		// Not something bookings care about, acknowledge so it is not resent
+		return PaymentEventResult{Ignored: true}, nil
+	}
+
+	booking, err := s.Store.Bookings().GetByPaymentIntent(event.IntentID)
+	if err != nil {
+		return PaymentEventResult{}, notFoundOr(err, "booking_not_found", "No booking for payment "+event.IntentID)
+	}
+
+	booking, err = s.settle(booking.ID, status)
+	if errors.Is(err, errBookingNotPayable) {
+		// Money taken for a booking that expired or was cancelled meanwhile goes back
+		if status == models.BookingConfirmed && booking.Status != models.BookingConfirmed {
+			refund := s.reversePayment(context.Background(), booking.ID)
+			return PaymentEventResult{Ignored: refund == nil, Booking: &booking, Refund: refund}, nil
+		}
+		// Already settled, for example a repeated event
+		return PaymentEventResult{Ignored: true, Booking: &booking}, nil
+	}
+	if err != nil {
+		return PaymentEventResult{}, apperror.Internal(err, "Failed to update booking")
+	}
+	return PaymentEventResult{Booking: &booking}, nil
+}
+
+// settleRefund records the result of a refund the provider finished later
+func (s *BookingService) settleRefund(event payments.Event) (PaymentEventResult, error) {
+	status := payments.RefundSucceeded
+	if event.Type == payments.EventRefundFailed {
+		status = payments.RefundFailed
+	}
+
+	refund, err := s.Refunds.Settle(s.Store, event.RefundID, status)
+	if err != nil {
+		return PaymentEventResult{}, notFoundOr(err, "refund_not_found", "No refund "+event.RefundID)
+	}
+	return PaymentEventResult{Refund: refund}, nil
+}
+
+// errBookingNotPayable means the booking moved on before the payment result arrived
+var errBookingNotPayable = errors.New("booking is no longer awaiting payment")
+
+// settle moves a pending booking to its final status, releasing the seats
+// unless it was confirmed. It returns errBookingNotPayable, along with the
+// booking as it is, if the booking has already left pending_payment.
+func (s *BookingService) settle(bookingID uint, status string) (models.Booking, error) {
+	var booking models.Booking
+	err := s.Store.Transaction(func(tx repository.Store) error {
+		var err error
+		booking, err = tx.Bookings().Lock(bookingID)
+		if err != nil {
+			return err
+		}
+		if booking.Status != models.BookingPendingPayment || !models.CanTransition(booking.Status, status) {
+			return errBookingNotPayable
+		}
+
+		if status != models.BookingConfirmed {
+			if err := tx.Bookings().ReleaseSeats(booking.ID); err != nil {
+				return err
+			}
+		}
+
+		booking.Status = status
+		return tx.Bookings().Update(booking.ID, map[string]interface{}{"status": status})
+	})
+	return booking, err
+}
+
+// Cancel cancels a booking and puts its seats back on sale, refunding what the
+// refund policy allows. Customers can only cancel their own bookings, staff
+// and admins can cancel anyone's. The refund is nil when nothing is owed.
+func (s *BookingService) Cancel(ctx context.Context, userID uint, role string, bookingID uint, reason string) (models.Booking, *models.Refund, error) {
+	var booking models.Booking
+	var refund *models.Refund
+	var unpaid bool
+	err := s.Store.Transaction(func(tx repository.Store) error {
+		var err error
+		// Locked so a payment result or a second cancellation waits for this one
+		booking, err = tx.Bookings().Lock(bookingID)
+		if err != nil {
+			return notFoundOr(err, "booking_not_found", "Booking not found")
+		}
+		unpaid = booking.Status == models.BookingPendingPayment
+
+		if booking.UserID != userID && role != models.RoleStaff && role != models.RoleAdmin {
+			return apperror.Forbidden("not_booking_owner", "You can only cancel your own bookings")
+		}
+		if !models.CanTransition(booking.Status, models.BookingCancelled) {
+			return apperror.Conflict("booking_not_cancellable", "Booking cannot be cancelled, it is "+booking.Status)
+		}
+
+		show, err := tx.Shows().Get(booking.ShowID)
+		if err != nil {
+			return notFoundOr(err, "show_not_found", "Show not found")
+		}
+
+		now := s.now()
+		if now.After(show.Time.Add(-s.CancellationCutoff)) {
+			return apperror.Conflict("cancellation_closed", fmt.Sprintf("Bookings can only be cancelled up to %s before the show", s.CancellationCutoff))
+		}
+
+		// Work out what is owed back before the status changes
+		refund, err = s.Refunds.Prepare(tx.Refunds(), booking, show, now)
+		if err != nil {
+			return apperror.Internal(err, "Failed to record refund")
+		}
+
+		if err := tx.Bookings().ReleaseSeats(booking.ID); err != nil {
+			return apperror.Internal(err, "Failed to release seats")
+		}
+
+		booking.Status = models.BookingCancelled
+		if err := tx.Bookings().Update(booking.ID, map[string]interface{}{
+			"status":        models.BookingCancelled,
+			"cancelled_by":  userID,
+			"cancelled_at":  now,
+			"cancel_reason": reason,
+		}); err != nil {
+			return apperror.Internal(err, "Failed to cancel booking")
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Booking{}, nil, internalOr(err, "Failed to complete cancellation")
+	}
+
+	// The cancellation stands even if the provider refuses the refund, the
+	// refund is then left failed for staff to follow up
+	if refund != nil {
+		if err := s.Refunds.Issue(ctx, s.Store, refund); err != nil {
+			log.Printf("❌ Error recording refund for booking %d: %v", booking.ID, err)
+		}
+	}
+	// Stop the customer paying for it later, or give the money back if they
+	// just did
+	if unpaid {
+		refund = s.voidPayment(ctx, booking)
+	}
+	return booking, refund, nil
+}
+
+// voidPayment cancels the payment of a booking that will not be confirmed. A
+// payment that went through before it could be cancelled is refunded in full.
+func (s *BookingService) voidPayment(ctx context.Context, booking models.Booking) *models.Refund {
+	if booking.PaymentIntentID == "" {
+		return nil
+	}
+	intent, err := s.Payments.Void(ctx, booking.PaymentIntentID)
+	if err != nil {
+		log.Printf("❌ Error voiding payment %s of booking %d: %v", booking.PaymentIntentID, booking.ID, err)
+		return nil
+	}
+	if intent.Status != payments.IntentSucceeded {
+		return nil
+	}
+	return s.reversePayment(ctx, booking.ID)
+}
+
+// reversePayment refunds in full a payment taken for a booking that was not
+// confirmed. Failures are logged and left for staff, like other refunds.
+func (s *BookingService) reversePayment(ctx context.Context, bookingID uint) *models.Refund {
+	var refund *models.Refund
+	err := s.Store.Transaction(func(tx repository.Store) error {
+		booking, err := tx.Bookings().Get(bookingID)
+		if err != nil {
+			return err
+		}
+		refund, err = s.Refunds.PrepareReversal(tx.Refunds(), booking)
+		return err
+	})
+	if err != nil {
+		log.Printf("❌ Error recording refund for booking %d: %v", bookingID, err)
+		return nil
+	}
+	if refund == nil {
+		return nil
+	}
+	if err := s.Refunds.Issue(ctx, s.Store, refund); err != nil {
+		log.Printf("❌ Error recording refund for booking %d: %v", bookingID, err)
+	}
+	return refund
+}
+
+// ListForUser returns one page of the user's bookings, newest show first, and
+// how many there are. when is "upcoming", "past" or empty for all of them.
+func (s *BookingService) ListForUser(userID uint, when string, page int, pageSize int) ([]BookingDetails, int64, error) {
+	var filter repository.BookingFilter
+	now := s.now()
+	switch when {
+	case "":
+	case "upcoming":
+		filter.ShowsAfter = &now
+	case "past":
+		filter.ShowsBefore = &now
+	default:
+		return nil, 0, apperror.Validation("invalid_query", "Invalid query parameters", apperror.FieldError{
+			Field: "when", Code: "oneof", Message: "must be upcoming or past",
+		})
+	}
+
+	bookings, total, err := s.Store.Bookings().ListForUser(userID, filter, page, pageSize)
+	if err != nil {
+		return nil, 0, apperror.Internal(err, "Unable to fetch bookings")
+	}
+
+	// Load the shows and movies these bookings belong to in two queries
+	showIDs := make([]uint, 0, len(bookings))
+	for _, booking := range bookings {
+		showIDs = append(showIDs, booking.ShowID)
+	}
+	shows, err := s.Store.Shows().ListByIDs(showIDs)
+	if err != nil {
+		return nil, 0, apperror.Internal(err, "Unable to fetch shows")
+	}
+
+	showMap := make(map[uint]models.Show)
+	movieIDs := make([]uint, 0, len(shows))
+	for _, show := range shows {
+		showMap[show.ID] = show
+		movieIDs = append(movieIDs, show.MovieID)
+	}
+
+	movies, err := s.Store.Movies().ListByIDs(movieIDs)
+	if err != nil {
+		return nil, 0, apperror.Internal(err, "Unable to fetch movies")
+	}
+	movieMap := make(map[uint]models.Movie)
+	for _, movie := range movies {
+		movieMap[movie.ID] = movie
+	}
+
+	results := make([]BookingDetails, 0, len(bookings))
+	for _, booking := range bookings {
+		show := showMap[booking.ShowID]
+		results = append(results, BookingDetails{Booking: booking, Show: show, Movie: movieMap[show.MovieID]})
+	}
+	return results, total, nil
+}
+
+// GetForUser returns one of the user's bookings with its seats, line items and refunds
+func (s *BookingService) GetForUser(userID uint, bookingID uint) (BookingDetails, error) {
+	booking, err := s.Store.Bookings().GetDetailed(bookingID)
+	if err != nil {
+		return BookingDetails{}, notFoundOr(err, "booking_not_found", "Booking not found")
+	}
+	if booking.UserID != userID {
+		return BookingDetails{}, apperror.Forbidden("not_booking_owner", "You can only view your own bookings")
+	}
+
+	show, err := s.Store.Shows().Get(booking.ShowID)
+	if err != nil {
+		return BookingDetails{}, notFoundOr(err, "show_not_found", "Show not found")
+	}
+
+	// The movie may have been removed since, the booking is still worth showing
+	movie, _ := s.Store.Movies().Get(show.MovieID)
+
+	return BookingDetails{Booking: booking, Show: show, Movie: movie}, nil
+}
+
+// ReleaseExpiredHolds makes every seat whose hold has lapsed available again
+func (s *BookingService) ReleaseExpiredHolds() (int64, error) {
+	return s.Store.Seats().ReleaseExpiredHolds(s.now())
+}
+
+// ExpireUnpaidBookings expires pending bookings whose payment window has passed and releases their seats
+func (s *BookingService) ExpireUnpaidBookings() (int64, error) {
+	return s.Store.Bookings().ExpireUnpaid(s.now())
+}
+
+// openShow returns the show if it still sells seats, that is if it exists
+// and has not been cancelled
+func openShow(store repository.Store, showID uint) (models.Show, error) {
+	show, err := store.Shows().Get(showID)
+	if err != nil {
+		return models.Show{}, notFoundOr(err, "show_not_found", "Show not found")
+	}
+	if show.CancelledAt != nil {
+		return models.Show{}, apperror.Conflict("show_cancelled", "Show has been cancelled")
+	}
+	return show, nil
+}
+
+// seatsByLabel returns the show's seats with the given labels, in order
+func seatsByLabel(store repository.Store, showID uint, labels []string) ([]models.Seat, error) {
+	showSeats, err := store.Seats().ListByShow(showID)
+	if err != nil {
+		return nil, apperror.Internal(err, "Failed to fetch seats")
+	}
+
+	seatMap := make(map[string]models.Seat)
+	for _, seat := range showSeats {
+		seatMap[fmt.Sprintf("%s%d", seat.Row, seat.Number)] = seat
+	}
+
+	seats := make([]models.Seat, 0, len(labels))
+	for _, label := range labels {
+		seat, exists := seatMap[label]
+		if !exists {
+			return nil, apperror.NotFound("seat_not_found", fmt.Sprintf("Seat %s not found", label))
+		}
+		seats = append(seats, seat)
+	}
+	return seats, nil
+}
+
+// holdExpired reports whether a held seat's hold has run out at the given time
+func holdExpired(seat models.Seat, now time.Time) bool {
+	return seat.HoldExpiresAt == nil || !seat.HoldExpiresAt.After(now)
+}
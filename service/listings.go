@@ -0,0 +1,308 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"ETE3/apperror"
+	"ETE3/models"
+	"ETE3/money"
+	"ETE3/repository"
+)
+
+// maxListingDays bounds the date range of one listings query
+const maxListingDays = 14
+
+// Times of day, by the hour shows start at in the query's time zone
+var timesOfDay = map[string]func(hour int) bool{
+	"morning":   func(hour int) bool { return hour >= 5 && hour < 12 },
+	"afternoon": func(hour int) bool { return hour >= 12 && hour < 17 },
+	"evening":   func(hour int) bool { return hour >= 17 && hour < 21 },
+	"night":     func(hour int) bool { return hour >= 21 || hour < 5 },
+}
+
+// ListingQuery selects the shows to list. Empty fields do not filter.
+type ListingQuery struct {
+	From      string // first day, YYYY-MM-DD, today if empty
+	To        string // last day, YYYY-MM-DD, the same as From if empty
+	Timezone  string // the days and times of day are in this zone, UTC if empty
+	City      string // matched case-insensitively, as is Language
+	TheaterID uint
+	MovieID   uint
+	Language  string
+	Format    string
+	// TimesOfDay are any of morning, afternoon, evening and night
+	TimesOfDay []string
+	// MinPrice and MaxPrice are decimal amounts such as "12.50". A show
+	// matches if any of its prices is in the range.
+	MinPrice string
+	MaxPrice string
+}
+
+// ShowListing is one show of a theater's listing
+type ShowListing struct {
+	ShowID         uint               `json:"show_id"`
+	Time           time.Time          `json:"time"`
+	EndsAt         time.Time          `json:"ends_at"`
+	Format         string             `json:"format"`
+	ScreenID       uint               `json:"screen_id"`
+	Screen         string             `json:"screen"`
+	Price          money.Money        `json:"price"`
+	Prices         []models.ShowPrice `json:"prices,omitempty"`
+	SeatsAvailable int64              `json:"seats_available"`
+}
+
+// TheaterListing is a theater and its shows of one movie
+type TheaterListing struct {
+	TheaterID uint          `json:"theater_id"`
+	Name      string        `json:"name"`
+	City      string        `json:"city"`
+	Address   string        `json:"address"`
+	Shows     []ShowListing `json:"shows"`
+}
+
+// MovieListing is a movie and the theaters showing it
+type MovieListing struct {
+	Movie    models.Movie     `json:"movie"`
+	Theaters []TheaterListing `json:"theaters"`
+}
+
+// Listings returns the shows that have not started yet in the query's date
+// range, grouped by movie and theater. Movies are ordered by their first
+// show, as are the theaters of each movie.
+func (s *SchedulingService) Listings(query ListingQuery) ([]MovieListing, error) {
+	invalid := func(field string, code string, message string) error {
+		return apperror.Validation("invalid_query", "Invalid query parameters", apperror.FieldError{
+			Field: field, Code: code, Message: message,
+		})
+	}
+
+	loc := time.UTC
+	if query.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(query.Timezone); err != nil {
+			return nil, invalid("timezone", "timezone", "must be an IANA time zone")
+		}
+	}
+
+	now := s.now()
+	first := time.Date(now.In(loc).Year(), now.In(loc).Month(), now.In(loc).Day(), 0, 0, 0, 0, loc)
+	if query.From != "" {
+		var err error
+		if first, err = time.ParseInLocation("2006-01-02", query.From, loc); err != nil {
+			return nil, invalid("from", "datetime", "must be formatted like 2006-01-02")
+		}
+	}
+	last := first
+	if query.To != "" {
+		var err error
+		if last, err = time.ParseInLocation("2006-01-02", query.To, loc); err != nil {
+			return nil, invalid("to", "datetime", "must be formatted like 2006-01-02")
+		}
+	}
+	if last.Before(first) {
+		return nil, invalid("to", "min", "must not be before from")
+	}
+	if !last.Before(first.AddDate(0, 0, maxListingDays)) {
+		return nil, invalid("to", "max", fmt.Sprintf("must be less than %d days after from", maxListingDays))
+	}
+
+	if query.Format != "" && !models.IsShowFormat(query.Format) {
+		return nil, invalid("format", "oneof", "must be one of 2D 3D IMAX")
+	}
+	for i, name := range query.TimesOfDay {
+		if _, ok := timesOfDay[name]; !ok {
+			return nil, invalid(fmt.Sprintf("time_of_day[%d]", i), "oneof", "must be one of morning afternoon evening night")
+		}
+	}
+	// Prices are read in each show's currency, check they read at all
+	for field, value := range map[string]string{"min_price": query.MinPrice, "max_price": query.MaxPrice} {
+		if value == "" {
+			continue
+		}
+		if amount, err := money.Parse(value, s.DefaultCurrency); err != nil || amount.Amount < 0 {
+			return nil, invalid(field, "price", "must be a non-negative amount such as 12.50")
+		}
+	}
+
+	// Shows that have started are no longer on sale
+	after := first
+	if now.After(after) {
+		after = now
+	}
+	shows, err := s.Store.Shows().Search(repository.ShowFilter{
+		After:     after,
+		Before:    last.AddDate(0, 0, 1),
+		City:      query.City,
+		TheaterID: query.TheaterID,
+		MovieID:   query.MovieID,
+		Language:  query.Language,
+		Format:    query.Format,
+	})
+	if err != nil {
+		return nil, apperror.Internal(err, "Failed to fetch shows")
+	}
+
+	// The bounds have to read in the currency of every show they are compared with
+	ranges := make(map[string]priceRange)
+	for _, show := range shows {
+		currency := show.Price.Currency
+		if _, ok := ranges[currency]; ok {
+			continue
+		}
+		bound := func(field string, value string, fallback int64) (int64, error) {
+			if value == "" {
+				return fallback, nil
+			}
+			amount, err := money.Parse(value, currency)
+			if err != nil {
+				return 0, invalid(field, "price", fmt.Sprintf("must be an amount in %s", currency))
+			}
+			return amount.Amount, nil
+		}
+		low, err := bound("min_price", query.MinPrice, 0)
+		if err != nil {
+			return nil, err
+		}
+		high, err := bound("max_price", query.MaxPrice, 1<<62)
+		if err != nil {
+			return nil, err
+		}
+		ranges[currency] = priceRange{low: low, high: high}
+	}
+
+	matched := shows[:0]
+	for _, show := range shows {
+		if listedAt(show, loc, query.TimesOfDay) && listedFor(show, ranges[show.Price.Currency]) {
+			matched = append(matched, show)
+		}
+	}
+	return s.groupListings(matched)
+}
+
+// listedAt reports whether the show starts at any of the times of day
+func listedAt(show models.Show, loc *time.Location, names []string) bool {
+	if len(names) == 0 {
+		return true
+	}
+	hour := show.Time.In(loc).Hour()
+	for _, name := range names {
+		if timesOfDay[name](hour) {
+			return true
+		}
+	}
+	return false
+}
+
+// priceRange bounds the prices of listed shows, in minor units of one currency
+type priceRange struct {
+	low  int64
+	high int64
+}
+
+// listedFor reports whether any of the show's prices is in the range
+func listedFor(show models.Show, bounds priceRange) bool {
+	prices := []money.Money{show.Price}
+	for _, price := range show.Prices {
+		prices = append(prices, price.Price)
+	}
+	for _, price := range prices {
+		if price.Amount >= bounds.low && price.Amount <= bounds.high {
+			return true
+		}
+	}
+	return false
+}
+
+// groupListings groups shows, ordered by time, by movie and theater
+func (s *SchedulingService) groupListings(shows []models.Show) ([]MovieListing, error) {
+	listings := []MovieListing{}
+	if len(shows) == 0 {
+		return listings, nil
+	}
+
+	screens, err := s.Store.Theaters().ListScreensByIDs(distinct(shows, func(show models.Show) uint { return show.ScreenID }))
+	if err != nil {
+		return nil, apperror.Internal(err, "Failed to fetch screens")
+	}
+	screensByID := make(map[uint]models.Screen, len(screens))
+	var theaterIDs []uint
+	for _, screen := range screens {
+		screensByID[screen.ID] = screen
+		theaterIDs = append(theaterIDs, screen.TheaterID)
+	}
+	theaters, err := s.Store.Theaters().ListByIDs(theaterIDs)
+	if err != nil {
+		return nil, apperror.Internal(err, "Failed to fetch theaters")
+	}
+	theatersByID := make(map[uint]models.Theater, len(theaters))
+	for _, theater := range theaters {
+		theatersByID[theater.ID] = theater
+	}
+	movies, err := s.Store.Movies().ListByIDs(distinct(shows, func(show models.Show) uint { return show.MovieID }))
+	if err != nil {
+		return nil, apperror.Internal(err, "Failed to fetch movies")
+	}
+	moviesByID := make(map[uint]models.Movie, len(movies))
+	for _, movie := range movies {
+		moviesByID[movie.ID] = movie
+	}
+	available, err := s.Store.Seats().CountAvailable(showIDs(shows), s.now())
+	if err != nil {
+		return nil, apperror.Internal(err, "Failed to count seats")
+	}
+
+	movieIndex := make(map[uint]int)
+	theaterIndex := make(map[[2]uint]int)
+	for _, show := range shows {
+		screen := screensByID[show.ScreenID]
+		theater := theatersByID[screen.TheaterID]
+
+		i, ok := movieIndex[show.MovieID]
+		if !ok {
+			i = len(listings)
+			movieIndex[show.MovieID] = i
+			listings = append(listings, MovieListing{Movie: moviesByID[show.MovieID], Theaters: []TheaterListing{}})
+		}
+		key := [2]uint{show.MovieID, theater.ID}
+		j, ok := theaterIndex[key]
+		if !ok {
+			j = len(listings[i].Theaters)
+			theaterIndex[key] = j
+			listings[i].Theaters = append(listings[i].Theaters, TheaterListing{
+				TheaterID: theater.ID,
+				Name:      theater.Name,
+				City:      theater.City,
+				Address:   theater.Address,
+			})
+		}
+
+		listings[i].Theaters[j].Shows = append(listings[i].Theaters[j].Shows, ShowListing{
+			ShowID:         show.ID,
+			Time:           show.Time,
+			EndsAt:         show.EndsAt,
+			Format:         show.Format,
+			ScreenID:       screen.ID,
+			Screen:         screen.Name,
+			Price:          show.Price,
+			Prices:         show.Prices,
+			SeatsAvailable: available[show.ID],
+		})
+	}
+	return listings, nil
+}
+
+// distinct returns the distinct, sorted values of key over the shows
+func distinct(shows []models.Show, key func(models.Show) uint) []uint {
+	seen := make(map[uint]bool)
+	var values []uint
+	for _, show := range shows {
+		if value := key(show); !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return values
+}
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"ETE3/apperror"
+	"ETE3/models"
+	"ETE3/repository"
+)
+
+// maxScheduleShows bounds how many shows one schedule can generate, and one
+// schedule spans a year at most
+const maxScheduleShows = 1000
+
+var scheduleWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ScheduleOccurrence is one show a schedule generates, with the show already
+// keeping the screen busy at that time if there is one
+type ScheduleOccurrence struct {
+	Show     models.Show  `json:"show"`
+	Conflict *models.Show `json:"conflict,omitempty"`
+}
+
+// SchedulePlan is what saving a schedule does: the shows it generates and, for
+// an edited schedule, how many upcoming shows of the old version it replaces
+type SchedulePlan struct {
+	Schedule    models.ShowSchedule
+	Occurrences []ScheduleOccurrence
+	Replaced    int
+}
+
+// Conflicts returns the occurrences that cannot be scheduled
+func (p SchedulePlan) Conflicts() []ScheduleOccurrence {
+	var conflicts []ScheduleOccurrence
+	for _, occurrence := range p.Occurrences {
+		if occurrence.Conflict != nil {
+			conflicts = append(conflicts, occurrence)
+		}
+	}
+	return conflicts
+}
+
+// Shows returns the shows the schedule generates
+func (p SchedulePlan) Shows() []models.Show {
+	shows := make([]models.Show, 0, len(p.Occurrences))
+	for _, occurrence := range p.Occurrences {
+		shows = append(shows, occurrence.Show)
+	}
+	return shows
+}
+
+// AddSchedule saves a schedule and generates its shows that are still to come.
+// Nothing is saved if any of them overlaps another show, the plan then tells
+// which. With dryRun the plan is only computed.
+func (s *SchedulingService) AddSchedule(schedule *models.ShowSchedule, dryRun bool) (SchedulePlan, error) {
+	return s.saveSchedule(schedule, false, dryRun)
+}
+
+// UpdateSchedule replaces a schedule. Its upcoming shows are regenerated from
+// the new version, past ones are left alone. Upcoming shows with seats held
+// or booked have to be dealt with first.
+func (s *SchedulingService) UpdateSchedule(id uint, update models.ShowSchedule, dryRun bool) (SchedulePlan, error) {
+	schedule, err := s.Store.Schedules().Get(id)
+	if err != nil {
+		return SchedulePlan{}, notFoundOr(err, "schedule_not_found", "Schedule not found")
+	}
+
+	update.Model = schedule.Model
+	return s.saveSchedule(&update, true, dryRun)
+}
+
+// GetSchedule returns a schedule with its upcoming shows
+func (s *SchedulingService) GetSchedule(id uint) (models.ShowSchedule, []models.Show, error) {
+	schedule, err := s.Store.Schedules().Get(id)
+	if err != nil {
+		return models.ShowSchedule{}, nil, notFoundOr(err, "schedule_not_found", "Schedule not found")
+	}
+
+	shows, err := s.Store.Shows().ListBySchedule(id, s.now())
+	if err != nil {
+		return models.ShowSchedule{}, nil, apperror.Internal(err, "Failed to fetch schedule")
+	}
+	return schedule, shows, nil
+}
+
+// CancelSchedule deletes a schedule and its upcoming shows, and returns how
+// many shows were cancelled
+func (s *SchedulingService) CancelSchedule(id uint) (int, error) {
+	var cancelled int
+	err := s.Store.Transaction(func(tx repository.Store) error {
+		if _, err := tx.Schedules().Get(id); err != nil {
+			return notFoundOr(err, "schedule_not_found", "Schedule not found")
+		}
+
+		upcoming, err := removableShows(tx, id, s.now())
+		if err != nil {
+			return err
+		}
+		if err := tx.Shows().DeleteMany(showIDs(upcoming)); err != nil {
+			return apperror.Internal(err, "Failed to cancel shows")
+		}
+		if err := tx.Schedules().Delete(id); err != nil {
+			return apperror.Internal(err, "Failed to cancel schedule")
+		}
+		cancelled = len(upcoming)
+		return nil
+	})
+	return cancelled, internalOr(err, "Failed to cancel schedule")
+}
+
+func (s *SchedulingService) saveSchedule(schedule *models.ShowSchedule, existing bool, dryRun bool) (SchedulePlan, error) {
+	if err := checkFormat(&schedule.Format); err != nil {
+		return SchedulePlan{}, err
+	}
+	if err := s.checkPrices(&schedule.Price, schedule.Prices); err != nil {
+		return SchedulePlan{}, err
+	}
+	now := s.now()
+	starts, err := scheduleTimes(*schedule, now)
+	if err != nil {
+		return SchedulePlan{}, err
+	}
+
+	plan := SchedulePlan{Schedule: *schedule}
+	err = s.Store.Transaction(func(tx repository.Store) error {
+		movie, screen, err := lockSlot(tx, schedule.MovieID, schedule.ScreenID)
+		if err != nil {
+			return err
+		}
+
+		// The upcoming shows of an edited schedule make way for the new ones
+		var replaced []models.Show
+		if existing {
+			if replaced, err = removableShows(tx, schedule.ID, now); err != nil {
+				return err
+			}
+		}
+		ignore := make(map[uint]bool, len(replaced))
+		for _, show := range replaced {
+			ignore[show.ID] = true
+		}
+		plan.Replaced = len(replaced)
+
+		for _, start := range starts {
+			show := models.Show{
+				MovieID:  schedule.MovieID,
+				ScreenID: schedule.ScreenID,
+				Time:     start,
+				EndsAt:   s.showEnd(start, movie),
+				Format:   schedule.Format,
+				Price:    schedule.Price,
+			}
+			for _, price := range schedule.Prices {
+				show.Prices = append(show.Prices, models.ShowPrice{Category: price.Category, Price: price.Price})
+			}
+
+			conflict, err := conflictFor(tx, show, ignore)
+			if err != nil {
+				return err
+			}
+			// Times close together can also make the schedule overlap itself
+			for i := len(plan.Occurrences) - 1; conflict == nil && i >= 0; i-- {
+				other := plan.Occurrences[i].Show
+				if other.Time.Before(show.EndsAt) && other.EndsAt.After(show.Time) {
+					conflict = &other
+				}
+			}
+			plan.Occurrences = append(plan.Occurrences, ScheduleOccurrence{Show: show, Conflict: conflict})
+		}
+
+		if conflicts := plan.Conflicts(); len(conflicts) > 0 && !dryRun {
+			return apperror.Conflict("schedule_conflict", fmt.Sprintf("%d of the schedule's shows overlap other shows on the screen", len(conflicts))).
+				With("conflicts", conflicts)
+		}
+		if dryRun {
+			return nil
+		}
+
+		if existing {
+			if err := tx.Shows().DeleteMany(showIDs(replaced)); err != nil {
+				return apperror.Internal(err, "Failed to replace shows")
+			}
+			if err := tx.Schedules().Update(schedule); err != nil {
+				return apperror.Internal(err, "Failed to update schedule")
+			}
+		} else if err := tx.Schedules().Create(schedule); err != nil {
+			return apperror.Internal(err, "Failed to create schedule")
+		}
+		plan.Schedule = *schedule
+
+		for i := range plan.Occurrences {
+			show := &plan.Occurrences[i].Show
+			show.ScheduleID = &schedule.ID
+			if err := createShow(tx, show, screen); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return plan, internalOr(err, "Failed to save schedule")
+}
+
+// removableShows returns a schedule's shows after now, which can only be
+// removed while none of their seats are booked or held. Lapsed holds do not
+// count, the seats are gone with the shows.
+func removableShows(tx repository.Store, scheduleID uint, now time.Time) ([]models.Show, error) {
+	upcoming, err := tx.Shows().ListBySchedule(scheduleID, now)
+	if err != nil {
+		return nil, apperror.Internal(err, "Failed to fetch the schedule's shows")
+	}
+
+	taken, err := tx.Seats().CountTaken(showIDs(upcoming), now)
+	if err != nil {
+		return nil, apperror.Internal(err, "Failed to fetch the schedule's shows")
+	}
+	if taken > 0 {
+		return nil, apperror.Conflict("schedule_has_bookings", fmt.Sprintf("%d seats of upcoming shows are held or booked", taken))
+	}
+	return upcoming, nil
+}
+
+func showIDs(shows []models.Show) []uint {
+	ids := make([]uint, 0, len(shows))
+	for _, show := range shows {
+		ids = append(ids, show.ID)
+	}
+	return ids
+}
+
+// scheduleTimes expands a schedule into the start times, in UTC, of its shows
+// after now
+func scheduleTimes(schedule models.ShowSchedule, now time.Time) ([]time.Time, error) {
+	invalid := func(field string, code string, message string) error {
+		return apperror.Validation("invalid_request", "Invalid request data", apperror.FieldError{
+			Field: field, Code: code, Message: message,
+		})
+	}
+
+	loc := time.UTC
+	if schedule.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(schedule.Timezone); err != nil {
+			return nil, invalid("timezone", "timezone", "must be an IANA time zone")
+		}
+	}
+
+	if len(schedule.Weekdays) == 0 {
+		return nil, invalid("weekdays", "required", "is required")
+	}
+	days := make(map[time.Weekday]bool)
+	for i, name := range schedule.Weekdays {
+		day, ok := scheduleWeekdays[strings.ToLower(name)]
+		if !ok {
+			return nil, invalid(fmt.Sprintf("weekdays[%d]", i), "oneof", "must be one of mon tue wed thu fri sat sun")
+		}
+		days[day] = true
+	}
+
+	if len(schedule.Times) == 0 {
+		return nil, invalid("times", "required", "is required")
+	}
+	clocks := make([]time.Time, 0, len(schedule.Times))
+	for i, value := range schedule.Times {
+		clock, err := time.Parse("15:04", value)
+		if err != nil {
+			return nil, invalid(fmt.Sprintf("times[%d]", i), "datetime", "must be formatted like 15:04")
+		}
+		clocks = append(clocks, clock)
+	}
+
+	first, err := time.ParseInLocation("2006-01-02", schedule.StartDate, loc)
+	if err != nil {
+		return nil, invalid("start_date", "datetime", "must be formatted like 2006-01-02")
+	}
+	last, err := time.ParseInLocation("2006-01-02", schedule.EndDate, loc)
+	if err != nil {
+		return nil, invalid("end_date", "datetime", "must be formatted like 2006-01-02")
+	}
+	if last.Before(first) {
+		return nil, invalid("end_date", "min", "must not be before start_date")
+	}
+	if last.After(first.AddDate(1, 0, 0)) {
+		return nil, invalid("end_date", "max", "must be at most a year after start_date")
+	}
+
+	var starts []time.Time
+	for date := first; !date.After(last); date = date.AddDate(0, 0, 1) {
+		if !days[date.Weekday()] {
+			continue
+		}
+		for _, clock := range clocks {
+			start := time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+			if start.After(now) {
+				starts = append(starts, start.UTC())
+			}
+		}
+		if len(starts) > maxScheduleShows {
+			return nil, invalid("end_date", "max", fmt.Sprintf("schedule would create more than %d shows", maxScheduleShows))
+		}
+	}
+	if len(starts) == 0 {
+		return nil, invalid("end_date", "future", "schedule has no shows in the future")
+	}
+
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	return starts, nil
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"ETE3/apperror"
+	"ETE3/models"
+	"ETE3/money"
+	"ETE3/notify"
+	"ETE3/refunds"
+	"ETE3/repository"
+)
+
+// SchedulingService manages the catalog: movies, theaters with their screens,
+// and the shows that put a movie on a screen
+type SchedulingService struct {
+	Store repository.Store
+
+	// DefaultCurrency is used for show prices given without a currency
+	DefaultCurrency string
+
+	// AdBuffer runs before each movie and CleaningBuffer after it, the screen
+	// is busy for both
+	AdBuffer       time.Duration
+	CleaningBuffer time.Duration
+
+	// Refunds pays back bookings of cancelled shows
+	Refunds *refunds.Service
+	// Notifier tells customers about changes to their shows, notices are
+	// only logged if it is nil
+	Notifier notify.Notifier
+
+	// Now returns the current time, time.Now if nil
+	Now func() time.Time
+}
+
+// NewSchedulingService returns a SchedulingService pricing in currency by
+// default and refunding through refunder
+func NewSchedulingService(store repository.Store, currency string, refunder *refunds.Service) *SchedulingService {
+	return &SchedulingService{Store: store, DefaultCurrency: currency, Refunds: refunder}
+}
+
+func (s *SchedulingService) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+	return s.Now()
+}
+
+func (s *SchedulingService) notifier() notify.Notifier {
+	if s.Notifier == nil {
+		return notify.Log{}
+	}
+	return s.Notifier
+}
+
+// AddMovie adds a movie to the catalog, coming soon unless it says otherwise
+func (s *SchedulingService) AddMovie(movie *models.Movie) error {
+	if movie.Status == "" {
+		movie.Status = models.MovieComingSoon
+	}
+	if err := s.Store.Movies().Create(movie); err != nil {
+		return apperror.Internal(err, "Failed to add movie")
+	}
+	return nil
+}
+
+// UpdateMovie replaces the details of a movie with those of update. A new
+// duration moves the end of the movie's upcoming shows, and is refused if
+// that makes one of them overlap the next show on its screen.
+func (s *SchedulingService) UpdateMovie(id uint, update models.Movie) (models.Movie, error) {
+	err := s.Store.Transaction(func(tx repository.Store) error {
+		movie, err := tx.Movies().Get(id)
+		if err != nil {
+			return notFoundOr(err, "movie_not_found", "Movie not found")
+		}
+
+		update.Model = movie.Model
+		if update.Status == "" {
+			update.Status = movie.Status
+		}
+		if err := tx.Movies().Update(&update); err != nil {
+			return apperror.Internal(err, "Failed to update movie")
+		}
+		if update.Duration != movie.Duration {
+			return s.retimeShows(tx, update)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Movie{}, internalOr(err, "Failed to update movie")
+	}
+	return update, nil
+}
+
+// retimeShows moves the end of the movie's upcoming shows to match its
+// duration, as long as their screens stay free for them
+func (s *SchedulingService) retimeShows(tx repository.Store, movie models.Movie) error {
+	shows, err := tx.Shows().ListByMovie(movie.ID, s.now())
+	if err != nil {
+		return apperror.Internal(err, "Failed to fetch the movie's shows")
+	}
+	for _, screenID := range distinct(shows, func(show models.Show) uint { return show.ScreenID }) {
+		if _, err := tx.Theaters().LockScreen(screenID); err != nil {
+			return apperror.Internal(err, "Failed to fetch screen")
+		}
+	}
+
+	for i := range shows {
+		shows[i].EndsAt = s.showEnd(shows[i].Time, movie)
+		if err := tx.Shows().Update(shows[i].ID, map[string]interface{}{"ends_at": shows[i].EndsAt}); err != nil {
+			return apperror.Internal(err, "Failed to update shows")
+		}
+	}
+	// Checked once every show has its new end, so they are compared with each other too
+	for _, show := range shows {
+		conflict, err := conflictFor(tx, show, map[uint]bool{show.ID: true})
+		if err != nil {
+			return err
+		}
+		if conflict != nil {
+			return apperror.Conflict("show_conflict", fmt.Sprintf("At %d minutes the show at %s would overlap another show on its screen", movie.Duration, show.Time.Format(time.RFC3339))).
+				With("show", show).
+				With("conflicting_show", *conflict)
+		}
+	}
+	return nil
+}
+
+// DeleteMovie removes a movie from the catalog. Movies with shows still to
+// come cannot be deleted, archive them or cancel the shows first.
+func (s *SchedulingService) DeleteMovie(id uint) error {
+	if _, err := s.Store.Movies().Get(id); err != nil {
+		return notFoundOr(err, "movie_not_found", "Movie not found")
+	}
+
+	upcoming, err := s.Store.Shows().CountByMovieAfter(id, s.now())
+	if err != nil {
+		return apperror.Internal(err, "Failed to delete movie")
+	}
+	if upcoming > 0 {
+		return apperror.Conflict("movie_has_shows", fmt.Sprintf("Movie still has %d upcoming shows", upcoming))
+	}
+
+	if err := s.Store.Movies().Delete(id); err != nil {
+		return apperror.Internal(err, "Failed to delete movie")
+	}
+	return nil
+}
+
+func (s *SchedulingService) ListMovies() ([]models.Movie, error) {
+	movies, err := s.Store.Movies().List()
+	if err != nil {
+		return nil, apperror.Internal(err, "Unable to fetch movies")
+	}
+	return movies, nil
+}
+
+func (s *SchedulingService) GetMovie(id uint) (models.Movie, error) {
+	movie, err := s.Store.Movies().Get(id)
+	if err != nil {
+		return models.Movie{}, notFoundOr(err, "movie_not_found", "Movie not found")
+	}
+	return movie, nil
+}
+
+// AddTheater creates a theater without screens, those are added separately so
+// each layout gets validated
+func (s *SchedulingService) AddTheater(theater *models.Theater) error {
+	theater.Screens = nil
+	if err := s.Store.Theaters().Create(theater); err != nil {
+		return apperror.Internal(err, "Failed to create theater")
+	}
+	return nil
+}
+
+// AddScreen adds a screen with its seat layout to an existing theater
+func (s *SchedulingService) AddScreen(screen *models.Screen) error {
+	if err := screen.Layout.Validate(); err != nil {
+		return apperror.Validation("invalid_request", "Invalid request data", apperror.FieldError{
+			Field: "layout", Code: "layout", Message: err.Error(),
+		})
+	}
+
+	if _, err := s.Store.Theaters().Get(screen.TheaterID); err != nil {
+		return notFoundOr(err, "theater_not_found", "Theater not found")
+	}
+
+	if err := s.Store.Theaters().CreateScreen(screen); err != nil {
+		return apperror.Internal(err, "Failed to create screen")
+	}
+	return nil
+}
+
+// ListTheaters returns every theater, or only those in city if it is not empty
+func (s *SchedulingService) ListTheaters(city string) ([]models.Theater, error) {
+	theaters, err := s.Store.Theaters().List(city)
+	if err != nil {
+		return nil, apperror.Internal(err, "Unable to fetch theaters")
+	}
+	return theaters, nil
+}
+
+// GetTheater returns a theater with its screens and their layouts
+func (s *SchedulingService) GetTheater(id uint) (models.Theater, error) {
+	theater, err := s.Store.Theaters().Get(id)
+	if err != nil {
+		return models.Theater{}, notFoundOr(err, "theater_not_found", "Theater not found")
+	}
+	return theater, nil
+}
+
+// AddShow schedules a show and generates its seats from the screen layout.
+// The show must start in the future and its screen must be free from then
+// until the movie, with the ads before and the cleaning after, is over.
+func (s *SchedulingService) AddShow(show *models.Show) error {
+	if show.Time.IsZero() {
+		return apperror.Validation("invalid_request", "Invalid request data", apperror.FieldError{
+			Field: "time", Code: "required", Message: "is required",
+		})
+	}
+	if !show.Time.After(s.now()) {
+		return apperror.Validation("invalid_request", "Invalid request data", apperror.FieldError{
+			Field: "time", Code: "future", Message: "must be in the future",
+		})
+	}
+	// Show times are compared in the database, so they are all kept in UTC
+	show.Time = show.Time.UTC()
+
+	if err := checkFormat(&show.Format); err != nil {
+		return err
+	}
+	if err := s.checkPrices(&show.Price, show.Prices); err != nil {
+		return err
+	}
+
+	err := s.Store.Transaction(func(tx repository.Store) error {
+		movie, screen, err := lockSlot(tx, show.MovieID, show.ScreenID)
+		if err != nil {
+			return err
+		}
+
+		show.EndsAt = s.showEnd(show.Time, movie)
+		conflict, err := conflictFor(tx, *show, nil)
+		if err != nil {
+			return err
+		}
+		if conflict != nil {
+			return apperror.Conflict("show_conflict", "The screen is busy with another show at that time").
+				With("conflicting_show", *conflict)
+		}
+
+		return createShow(tx, show, screen)
+	})
+	return internalOr(err, "Failed to create show")
+}
+
+// checkPrices defaults the currency of prices given without one to the show's,
+// and makes sure no amount is negative and every category price is in that currency
+func (s *SchedulingService) checkPrices(price *money.Money, prices []models.ShowPrice) error {
+	if price.Currency == "" {
+		price.Currency = s.DefaultCurrency
+	}
+	if !money.ValidCurrency(price.Currency) {
+		return apperror.Validation("invalid_request", "Invalid request data", apperror.FieldError{
+			Field: "price.currency", Code: "currency", Message: "is not an ISO 4217 code: " + price.Currency,
+		})
+	}
+
+	if price.Amount < 0 {
+		return apperror.Validation("invalid_request", "Invalid request data", apperror.FieldError{
+			Field: "price.amount", Code: "min", Message: "must not be negative",
+		})
+	}
+
+	for i, categoryPrice := range prices {
+		if categoryPrice.Price.Amount < 0 {
+			return apperror.Validation("invalid_request", "Invalid request data", apperror.FieldError{
+				Field: fmt.Sprintf("prices[%d].price.amount", i), Code: "min", Message: "must not be negative",
+			})
+		}
+		if !models.IsSeatCategory(categoryPrice.Category) {
+			return apperror.Validation("invalid_request", "Invalid request data", apperror.FieldError{
+				Field: fmt.Sprintf("prices[%d].category", i), Code: "category", Message: "is not a seat category: " + categoryPrice.Category,
+			})
+		}
+		if categoryPrice.Price.Currency == "" {
+			prices[i].Price.Currency = price.Currency
+		} else if categoryPrice.Price.Currency != price.Currency {
+			return apperror.Validation("invalid_request", "Invalid request data", apperror.FieldError{
+				Field: fmt.Sprintf("prices[%d].price.currency", i), Code: "currency", Message: "must match the show's currency " + price.Currency,
+			})
+		}
+	}
+	return nil
+}
+
+// checkFormat defaults an empty show format to 2D and rejects unknown ones
+func checkFormat(format *string) error {
+	if *format == "" {
+		*format = models.Format2D
+	}
+	if !models.IsShowFormat(*format) {
+		return apperror.Validation("invalid_request", "Invalid request data", apperror.FieldError{
+			Field: "format", Code: "oneof", Message: "must be one of 2D 3D IMAX",
+		})
+	}
+	return nil
+}
+
+// lockSlot loads the movie and screen new shows are for. Shows run on a
+// screen, whose layout decides the seats. Locking it keeps two requests
+// scheduling at once from both fitting the same slot.
+func lockSlot(tx repository.Store, movieID uint, screenID uint) (models.Movie, models.Screen, error) {
+	movie, err := tx.Movies().Get(movieID)
+	if err != nil {
+		return models.Movie{}, models.Screen{}, notFoundOr(err, "movie_not_found", "Movie not found")
+	}
+	if movie.Status == models.MovieArchived {
+		return models.Movie{}, models.Screen{}, apperror.Conflict("movie_archived", "Archived movies cannot get new shows")
+	}
+
+	screen, err := tx.Theaters().LockScreen(screenID)
+	if err != nil {
+		return models.Movie{}, models.Screen{}, notFoundOr(err, "screen_not_found", "Screen not found")
+	}
+	return movie, screen, nil
+}
+
+// conflictFor returns a show keeping the screen busy while show would run, or
+// nil if it is free. Shows in ignore are about to be replaced and do not count.
+func conflictFor(tx repository.Store, show models.Show, ignore map[uint]bool) (*models.Show, error) {
+	overlapping, err := tx.Shows().ListOverlapping(show.ScreenID, show.Time, show.EndsAt)
+	if err != nil {
+		return nil, apperror.Internal(err, "Failed to check the screen's schedule")
+	}
+	for _, other := range overlapping {
+		if !ignore[other.ID] {
+			return &other, nil
+		}
+	}
+	return nil, nil
+}
+
+// createShow stores the show with the seats of its screen
+func createShow(tx repository.Store, show *models.Show, screen models.Screen) error {
+	if err := tx.Shows().Create(show); err != nil {
+		return apperror.Internal(err, "Failed to create show")
+	}
+	if err := tx.Seats().CreateMany(screen.Layout.SeatsForShow(show.ID)); err != nil {
+		return apperror.Internal(err, "Failed to create seats")
+	}
+	return nil
+}
+
+// SeedShow stores a show of movie on screen for fixtures, with the same end
+// time and overlap check as AddShow but none of its checks on the request. If
+// the screen is busy nothing is stored and the show in the way is returned.
+func (s *SchedulingService) SeedShow(tx repository.Store, show *models.Show, movie models.Movie, screen models.Screen) (*models.Show, error) {
+	show.Time = show.Time.UTC()
+	show.EndsAt = s.showEnd(show.Time, movie)
+	conflict, err := conflictFor(tx, *show, nil)
+	if err != nil || conflict != nil {
+		return conflict, err
+	}
+	return nil, createShow(tx, show, screen)
+}
+
+// showEnd is when a show of movie starting at start frees its screen
+func (s *SchedulingService) showEnd(start time.Time, movie models.Movie) time.Time {
+	return start.Add(s.AdBuffer + time.Duration(movie.Duration)*time.Minute + s.CleaningBuffer)
+}
+
+// ShowsByMovie returns the upcoming shows of a movie, which may be none
+func (s *SchedulingService) ShowsByMovie(movieID uint) ([]models.Show, error) {
+	if _, err := s.Store.Movies().Get(movieID); err != nil {
+		return nil, notFoundOr(err, "movie_not_found", "Movie not found")
+	}
+
+	shows, err := s.Store.Shows().ListByMovie(movieID, s.now())
+	if err != nil {
+		return nil, apperror.Internal(err, "Unable to fetch shows")
+	}
+	return shows, nil
+}
+
+// AvailableSeats returns the show, needed to price each seat by its category,
+// and the seats still on sale
+func (s *SchedulingService) AvailableSeats(showID uint) (models.Show, []models.Seat, error) {
+	show, err := openShow(s.Store, showID)
+	if err != nil {
+		return models.Show{}, nil, err
+	}
+
+	seats, err := s.Store.Seats().ListAvailable(showID)
+	if err != nil {
+		return models.Show{}, nil, apperror.Internal(err, "Failed to fetch available seats")
+	}
+	return show, seats, nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"fmt"
+
+	"ETE3/apperror"
+	"ETE3/models"
+	"ETE3/money"
+)
+
+// States of a seat on a seat map
+const (
+	SeatMapAvailable = "available"
+	SeatMapHeld      = "held"
+	SeatMapBooked    = "booked"
+	// In the layout but not on sale for the show
+	SeatMapBlocked = "blocked"
+)
+
+// SeatMap is the full layout of a show's screen with the state of every seat
+type SeatMap struct {
+	ShowID   uint          `json:"show_id"`
+	ScreenID uint          `json:"screen_id"`
+	Screen   string        `json:"screen"`
+	Rows     []SeatMapRow  `json:"rows"`
+	Counts   SeatMapCounts `json:"counts"`
+}
+
+// SeatMapRow is a row of the layout, front row first
+type SeatMapRow struct {
+	Label     string            `json:"label"`
+	Y         int               `json:"y"`
+	Positions []SeatMapPosition `json:"positions"`
+}
+
+// SeatMapPosition is a seat, aisle or gap. Only seats have the fields after X.
+type SeatMapPosition struct {
+	Kind     string       `json:"kind"`
+	X        int          `json:"x"`
+	Seat     string       `json:"seat,omitempty"` // label, e.g. "A1"
+	Number   int          `json:"number,omitempty"`
+	Category string       `json:"category,omitempty"`
+	State    string       `json:"state,omitempty"`
+	Price    *money.Money `json:"price,omitempty"`
+}
+
+// SeatMapCounts counts the seats in each state
+type SeatMapCounts struct {
+	Available int `json:"available"`
+	Held      int `json:"held"`
+	Booked    int `json:"booked"`
+	Blocked   int `json:"blocked"`
+}
+
+// SeatMap returns the layout of the show's screen with the state, category
+// and price of every seat. Seats whose hold has lapsed are available. Seats of
+// the layout the show has none of cannot be sold and are blocked, without a price.
+func (s *SchedulingService) SeatMap(showID uint) (SeatMap, error) {
+	show, err := openShow(s.Store, showID)
+	if err != nil {
+		return SeatMap{}, err
+	}
+	screen, err := s.Store.Theaters().GetScreen(show.ScreenID)
+	if err != nil {
+		return SeatMap{}, apperror.Internal(err, "Failed to fetch screen")
+	}
+	seats, err := s.Store.Seats().ListByShow(show.ID)
+	if err != nil {
+		return SeatMap{}, apperror.Internal(err, "Failed to fetch seats")
+	}
+
+	byLabel := make(map[string]models.Seat, len(seats))
+	for _, seat := range seats {
+		byLabel[fmt.Sprintf("%s%d", seat.Row, seat.Number)] = seat
+	}
+
+	now := s.now()
+	seatMap := SeatMap{ShowID: show.ID, ScreenID: screen.ID, Screen: screen.Name, Rows: []SeatMapRow{}}
+	for y, row := range screen.Layout.Rows {
+		mapRow := SeatMapRow{Label: row.Label, Y: y, Positions: []SeatMapPosition{}}
+		for x, position := range row.Positions {
+			if position.Kind != models.PositionSeat {
+				mapRow.Positions = append(mapRow.Positions, SeatMapPosition{Kind: position.Kind, X: x})
+				continue
+			}
+			category := position.Type
+			if category == "" {
+				category = models.SeatStandard
+			}
+			label := fmt.Sprintf("%s%d", row.Label, position.Number)
+			price := show.PriceFor(category)
+			mapPosition := SeatMapPosition{
+				Kind:     position.Kind,
+				X:        x,
+				Seat:     label,
+				Number:   position.Number,
+				Category: category,
+				Price:    &price,
+			}
+
+			seat, ok := byLabel[label]
+			switch {
+			case !ok:
+				mapPosition.State = SeatMapBlocked
+				mapPosition.Price = nil
+				seatMap.Counts.Blocked++
+			case seat.Status == models.Booked:
+				mapPosition.State = SeatMapBooked
+				seatMap.Counts.Booked++
+			case seat.Status == models.Held && !holdExpired(seat, now):
+				mapPosition.State = SeatMapHeld
+				seatMap.Counts.Held++
+			default:
+				mapPosition.State = SeatMapAvailable
+				seatMap.Counts.Available++
+			}
+			mapRow.Positions = append(mapRow.Positions, mapPosition)
+		}
+		seatMap.Rows = append(seatMap.Rows, mapRow)
+	}
+	return seatMap, nil
+}
@@ -0,0 +1,33 @@
+// Package service holds the business logic of the booking system. Services
+// work through the repository interfaces, never gin or a global database, and
+// report failures the client should see as *apperror.Error.
+package service
+
+import (
+	"errors"
+
+	"ETE3/apperror"
+	"ETE3/repository"
+)
+
+// notFoundOr reports a missing record as not found with the given code and
+// message, and any other error as internal
+func notFoundOr(err error, code string, message string) *apperror.Error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return apperror.NotFound(code, message)
+	}
+	return apperror.Internal(err, message)
+}
+
+// internalOr passes errors meant for the client through and hides anything
+// else, like a failed commit, behind message
+func internalOr(err error, message string) error {
+	if err == nil {
+		return nil
+	}
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return apperror.Internal(err, message)
+}
@@ -0,0 +1,1020 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"ETE3/apperror"
+	"ETE3/config"
+	"ETE3/db"
+	"ETE3/migrations"
+	"ETE3/models"
+	"ETE3/money"
+	"ETE3/notify"
+	"ETE3/payments"
+	"ETE3/refunds"
+	"ETE3/repository"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// newTestBookings returns a BookingService on its own in-memory database,
+// with its clock stopped at the returned pointer
+func newTestBookings(t *testing.T) (*gorm.DB, *BookingService, *time.Time) {
+	testDB, err := db.Open(config.DatabaseConfig{Driver: db.DriverSQLiteMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.Up(testDB); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := payments.NewFake("test-secret")
+	bookings := NewBookingService(repository.NewStore(testDB), fake, &refunds.Service{
+		Provider: fake,
+		Policy:   refunds.Policy{FullRefundBefore: 24 * time.Hour, PartialRefundBPS: 5000},
+	})
+
+	clock := time.Date(2025, time.April, 7, 9, 0, 0, 0, time.UTC)
+	bookings.Now = func() time.Time { return clock }
+	return testDB, bookings, &clock
+}
+
+// code returns the apperror code of err, or "" if it is not one
+func code(err error) string {
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return ""
+}
+
+// Test Book stores taxed line items and keeps them when show prices change
+func TestBookStoresLineItems(t *testing.T) {
+	t.Parallel()
+	testDB, bookings, _ := newTestBookings(t)
+	bookings.TaxRateBPS = 1800
+
+	testDB.Create(&models.Show{MovieID: 1, Price: money.New(1250, "USD")})
+	testDB.Create(&models.Seat{ShowID: 1, Row: "A", Number: 1, Type: models.SeatStandard, Status: models.Available})
+
+	_, err := bookings.Hold(1, 1, []string{"A1"})
+	assert.NoError(t, err)
+	booked, err := bookings.Book(context.Background(), 1, 1, []string{"A1"})
+	assert.NoError(t, err)
+	assert.Equal(t, models.BookingPendingPayment, booked.Booking.Status)
+	assert.NotEmpty(t, booked.Intent.ClientSecret)
+
+	// Repricing the show does not change what was sold
+	testDB.Model(&models.Show{}).Where("id = ?", 1).Update("price_amount", 9900)
+
+	var booking models.Booking
+	testDB.Preload("LineItems").First(&booking)
+	assert.Equal(t, money.New(1475, "USD"), booking.TotalPrice)
+	assert.Len(t, booking.LineItems, 1)
+
+	item := booking.LineItems[0]
+	assert.Equal(t, uint(1), item.SeatID)
+	assert.Equal(t, money.New(1250, "USD"), item.UnitPrice)
+	assert.Equal(t, money.New(225, "USD"), item.Tax)
+	assert.Equal(t, money.New(1475, "USD"), item.Total)
+}
+
+// Test ReleaseExpiredHolds only releases holds that have run out
+func TestReleaseExpiredHolds(t *testing.T) {
+	t.Parallel()
+	testDB, bookings, clock := newTestBookings(t)
+
+	testDB.Create(&models.Show{MovieID: 1})
+	testDB.Create(&models.Seat{ShowID: 1, Row: "A", Number: 1, Status: models.Available})
+
+	_, err := bookings.Hold(1, 1, []string{"A1"})
+	assert.NoError(t, err)
+
+	// Nothing to release while the hold is still valid
+	released, err := bookings.ReleaseExpiredHolds()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), released)
+
+	*clock = clock.Add(bookings.HoldTTL + time.Second)
+
+	// An expired hold can no longer be booked
+	_, err = bookings.Book(context.Background(), 1, 1, []string{"A1"})
+	assert.Equal(t, "hold_expired", code(err))
+
+	released, err = bookings.ReleaseExpiredHolds()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), released)
+
+	var seat models.Seat
+	testDB.First(&seat)
+	assert.Equal(t, models.Available, seat.Status)
+	assert.Equal(t, uint(0), seat.HeldBy)
+}
+
+// Test unpaid bookings expire, whether someone tries to pay late or not
+func TestExpireUnpaidBookings(t *testing.T) {
+	t.Parallel()
+	testDB, bookings, clock := newTestBookings(t)
+	ctx := context.Background()
+
+	testDB.Create(&models.Show{MovieID: 1, Price: money.New(1000, "USD")})
+	testDB.Create(&models.Seat{ShowID: 1, Row: "A", Number: 1, Status: models.Available})
+
+	bookings.Hold(1, 1, []string{"A1"})
+	booked, err := bookings.Book(ctx, 1, 1, []string{"A1"})
+	assert.NoError(t, err)
+
+	expired, err := bookings.ExpireUnpaidBookings()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), expired)
+
+	*clock = clock.Add(bookings.PaymentTTL + time.Second)
+
+	// Paying too late is refused
+	_, err = bookings.ConfirmPayment(ctx, 1, booked.Booking.ID)
+	assert.Equal(t, "payment_expired", code(err))
+
+	var booking models.Booking
+	testDB.First(&booking, booked.Booking.ID)
+	assert.Equal(t, models.BookingExpired, booking.Status)
+
+	var seat models.Seat
+	testDB.First(&seat)
+	assert.Equal(t, models.Available, seat.Status)
+
+	// The reaper expires bookings nobody tried to pay for
+	bookings.Hold(1, 1, []string{"A1"})
+	bookings.Book(ctx, 1, 1, []string{"A1"})
+	*clock = clock.Add(bookings.PaymentTTL + time.Second)
+
+	expired, err = bookings.ExpireUnpaidBookings()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), expired)
+
+	testDB.First(&seat)
+	assert.Equal(t, models.Available, seat.Status)
+	var active int64
+	testDB.Model(&models.BookingSeat{}).Where("active IS NOT NULL").Count(&active)
+	assert.Equal(t, int64(0), active)
+}
+
+// Test holds and payment windows expire at the right time when the clock is
+// not in UTC. SQLite compares times as text, so they must all be stored in UTC.
+func TestExpiriesWithLocalClock(t *testing.T) {
+	t.Parallel()
+	testDB, bookings, clock := newTestBookings(t)
+	ctx := context.Background()
+	*clock = clock.In(time.FixedZone("EDT", -4*60*60))
+
+	testDB.Create(&models.Show{MovieID: 1, Price: money.New(1000, "USD")})
+	testDB.Create(&models.Seat{ShowID: 1, Row: "A", Number: 1, Status: models.Available})
+
+	_, err := bookings.Hold(1, 1, []string{"A1"})
+	assert.NoError(t, err)
+	taken, err := bookings.Store.Seats().CountTaken([]uint{1}, *clock)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), taken)
+	released, err := bookings.ReleaseExpiredHolds()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), released)
+
+	_, err = bookings.Book(ctx, 1, 1, []string{"A1"})
+	assert.NoError(t, err)
+	expired, err := bookings.ExpireUnpaidBookings()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), expired)
+
+	*clock = clock.Add(bookings.PaymentTTL + time.Second)
+	expired, err = bookings.ExpireUnpaidBookings()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), expired)
+}
+
+// Test parallel hold and book attempts on one seat: exactly one booking wins.
+// SQLite runs them one after another, so the losers are stopped by the seat
+// status; TestSeatUpdateRejectsStaleVersion in repository covers the version
+// check that stops them when writers do overlap.
+func TestConcurrentBookingsSameSeat(t *testing.T) {
+	t.Parallel()
+	testDB, bookings, _ := newTestBookings(t)
+
+	testDB.Create(&models.Show{MovieID: 1, Price: money.New(1000, "USD")})
+	testDB.Create(&models.Seat{ShowID: 1, Row: "A", Number: 1, Status: models.Available})
+
+	const attempts = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 1; i <= attempts; i++ {
+		wg.Add(1)
+		go func(userID uint) {
+			defer wg.Done()
+			bookings.Hold(userID, 1, []string{"A1"})
+			_, err := bookings.Book(context.Background(), userID, 1, []string{"A1"})
+			errs <- err
+		}(uint(i))
+	}
+	wg.Wait()
+	close(errs)
+
+	won := 0
+	for err := range errs {
+		if err == nil {
+			won++
+		} else {
+			var appErr *apperror.Error
+			if assert.ErrorAs(t, err, &appErr) {
+				assert.Equal(t, apperror.KindConflict, appErr.Kind)
+			}
+		}
+	}
+	assert.Equal(t, 1, won)
+
+	var count int64
+	testDB.Model(&models.Booking{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+// Test Cancel refunds paid bookings according to the refund policy
+func TestCancelRefunds(t *testing.T) {
+	t.Parallel()
+	testDB, bookings, clock := newTestBookings(t)
+	ctx := context.Background()
+
+	testDB.Create(&models.Show{MovieID: 1, Time: clock.Add(48 * time.Hour), Price: money.New(1000, "USD")})
+	testDB.Create(&models.Seat{ShowID: 1, Row: "A", Number: 1, Status: models.Available})
+	testDB.Create(&models.Seat{ShowID: 1, Row: "A", Number: 2, Status: models.Available})
+
+	var ids []uint
+	for _, seat := range []string{"A1", "A2"} {
+		bookings.Hold(1, 1, []string{seat})
+		booked, err := bookings.Book(ctx, 1, 1, []string{seat})
+		assert.NoError(t, err)
+		_, err = bookings.ConfirmPayment(ctx, 1, booked.Booking.ID)
+		assert.NoError(t, err)
+		ids = append(ids, booked.Booking.ID)
+	}
+
+	// Someone else cannot cancel, staff can
+	_, _, err := bookings.Cancel(ctx, 2, models.RoleCustomer, ids[0], "")
+	assert.Equal(t, "not_booking_owner", code(err))
+
+	// Well ahead of the show everything comes back
+	_, refund, err := bookings.Cancel(ctx, 2, models.RoleStaff, ids[0], "")
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(1000, "USD"), refund.Amount)
+
+	// Within a day of the show only half does
+	*clock = clock.Add(30 * time.Hour)
+	_, refund, err = bookings.Cancel(ctx, 1, models.RoleCustomer, ids[1], "")
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(500, "USD"), refund.Amount)
+
+	var full, partial models.Booking
+	testDB.Preload("Refunds").First(&full, ids[0])
+	testDB.Preload("Refunds").First(&partial, ids[1])
+	assert.Equal(t, models.RefundSucceeded, full.RefundStatus)
+	assert.Equal(t, models.RefundSucceeded, partial.RefundStatus)
+	assert.Len(t, partial.Refunds, 1)
+	assert.NotEmpty(t, partial.Refunds[0].ProviderRefundID)
+
+	// The provider refuses to refund more than was paid
+	_, err = bookings.Payments.Refund(ctx, partial.PaymentIntentID, money.New(600, "USD"))
+	assert.Error(t, err)
+}
+
+// lateProvider runs beforeCapture ahead of every capture, so bookings can
+// change while the provider is busy
+type lateProvider struct {
+	*payments.Fake
+	beforeCapture func()
+}
+
+func (p lateProvider) Capture(ctx context.Context, intentID string) (payments.Intent, error) {
+	p.beforeCapture()
+	return p.Fake.Capture(ctx, intentID)
+}
+
+// Test money taken for a booking that expired or was cancelled meanwhile is
+// given back, and an unpaid booking that is cancelled can no longer be paid
+func TestLatePaymentsAreRefunded(t *testing.T) {
+	t.Parallel()
+	testDB, bookings, clock := newTestBookings(t)
+	ctx := context.Background()
+	fake := bookings.Payments.(*payments.Fake)
+
+	testDB.Create(&models.Show{MovieID: 1, Time: clock.Add(48 * time.Hour), Price: money.New(1000, "USD")})
+	for number := 1; number <= 3; number++ {
+		testDB.Create(&models.Seat{ShowID: 1, Row: "A", Number: number, Status: models.Available})
+	}
+	book := func(seat string) models.Booking {
+		_, err := bookings.Hold(1, 1, []string{seat})
+		assert.NoError(t, err)
+		booked, err := bookings.Book(ctx, 1, 1, []string{seat})
+		assert.NoError(t, err)
+		return booked.Booking
+	}
+	reload := func(id uint) models.Booking {
+		var booking models.Booking
+		testDB.First(&booking, id)
+		return booking
+	}
+
+	// The reaper expires the booking while the capture is in flight
+	late := book("A1")
+	bookings.Payments = lateProvider{Fake: fake, beforeCapture: func() {
+		*clock = clock.Add(bookings.PaymentTTL + time.Minute)
+		_, err := bookings.ExpireUnpaidBookings()
+		assert.NoError(t, err)
+	}}
+	_, err := bookings.ConfirmPayment(ctx, 1, late.ID)
+	assert.Equal(t, "booking_not_payable", code(err))
+	bookings.Payments = fake
+	assert.Equal(t, models.BookingExpired, reload(late.ID).Status)
+	assert.Equal(t, models.RefundSucceeded, reload(late.ID).RefundStatus)
+
+	// The customer pays the provider after the booking expired
+	expired := book("A2")
+	*clock = clock.Add(bookings.PaymentTTL + time.Minute)
+	_, err = bookings.ExpireUnpaidBookings()
+	assert.NoError(t, err)
+	_, err = fake.Capture(ctx, expired.PaymentIntentID)
+	assert.NoError(t, err)
+	payload, signature, err := fake.SignedEvent(payments.Event{ID: "evt_1", Type: payments.EventPaymentSucceeded, IntentID: expired.PaymentIntentID})
+	assert.NoError(t, err)
+	result, err := bookings.HandlePaymentEvent(payload, signature)
+	assert.NoError(t, err)
+	if assert.NotNil(t, result.Refund) {
+		assert.Equal(t, money.New(1000, "USD"), result.Refund.Amount)
+		assert.Equal(t, models.RefundSucceeded, result.Refund.Status)
+	}
+	// A repeated event does not refund twice
+	result, err = bookings.HandlePaymentEvent(payload, signature)
+	assert.NoError(t, err)
+	assert.True(t, result.Ignored)
+	assert.Nil(t, result.Refund)
+
+	// A cancelled unpaid booking cannot be paid afterwards
+	unpaid := book("A3")
+	_, refund, err := bookings.Cancel(ctx, 1, models.RoleCustomer, unpaid.ID, "")
+	assert.NoError(t, err)
+	assert.Nil(t, refund)
+	_, err = fake.Capture(ctx, unpaid.PaymentIntentID)
+	assert.ErrorIs(t, err, payments.ErrDeclined)
+}
+
+// downProvider cannot start payments
+type downProvider struct {
+	*payments.Fake
+}
+
+func (downProvider) CreateIntent(ctx context.Context, amount money.Money, reference string) (payments.Intent, error) {
+	return payments.Intent{}, errors.New("provider unavailable")
+}
+
+// Test a booking whose payment cannot be started fails and frees its seats
+func TestBookReleasesSeatsWhenPaymentCannotStart(t *testing.T) {
+	t.Parallel()
+	testDB, bookings, clock := newTestBookings(t)
+	ctx := context.Background()
+	bookings.Payments = downProvider{bookings.Payments.(*payments.Fake)}
+
+	testDB.Create(&models.Show{MovieID: 1, Time: clock.Add(48 * time.Hour), Price: money.New(1000, "USD")})
+	testDB.Create(&models.Seat{ShowID: 1, Row: "A", Number: 1, Status: models.Available})
+
+	_, err := bookings.Hold(1, 1, []string{"A1"})
+	assert.NoError(t, err)
+	_, err = bookings.Book(ctx, 1, 1, []string{"A1"})
+	assert.Equal(t, "payment_provider_error", code(err))
+
+	var booking models.Booking
+	testDB.First(&booking)
+	assert.Equal(t, models.BookingFailed, booking.Status)
+	var seat models.Seat
+	testDB.First(&seat)
+	assert.Equal(t, models.Available, seat.Status)
+}
+
+// Test BootstrapAdmin creates the admin once and promotes existing users
+func TestBootstrapAdmin(t *testing.T) {
+	t.Parallel()
+	testDB, bookings, _ := newTestBookings(t)
+	auth := NewAuthService(bookings.Store, []byte("test-secret"))
+
+	assert.NoError(t, auth.BootstrapAdmin("admin@example.com", "secret"))
+	assert.NoError(t, auth.BootstrapAdmin("admin@example.com", "secret"))
+
+	var count int64
+	testDB.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	testDB.Create(&models.User{Email: "user@example.com", Role: models.RoleCustomer})
+	assert.NoError(t, auth.BootstrapAdmin("user@example.com", "secret"))
+
+	var user models.User
+	testDB.Where("email = ?", "user@example.com").First(&user)
+	assert.Equal(t, models.RoleAdmin, user.Role)
+
+	token, err := auth.Login("admin@example.com", "secret")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+}
+
+// Test AddShow checks prices and the screen before scheduling anything
+func TestAddShow(t *testing.T) {
+	t.Parallel()
+	testDB, bookings, clock := newTestBookings(t)
+	scheduling := NewSchedulingService(bookings.Store, "EUR", bookings.Refunds)
+	scheduling.Now = bookings.Now
+
+	testDB.Create(&models.Movie{Title: "Test Movie", Duration: 120})
+	testDB.Create(&models.Theater{Name: "Test Theater", City: "Test City"})
+	testDB.Create(&models.Screen{TheaterID: 1, Name: "Screen 1", Layout: models.GridLayout("AB", 5)})
+	at := clock.Add(24 * time.Hour)
+
+	err := scheduling.AddShow(&models.Show{MovieID: 1, ScreenID: 1, Time: at, Prices: []models.ShowPrice{
+		{Category: "balcony", Price: money.New(1500, "")},
+	}})
+	assert.Equal(t, "invalid_request", code(err))
+
+	err = scheduling.AddShow(&models.Show{MovieID: 1, ScreenID: 1, Time: at, Price: money.New(-100, "")})
+	assert.Equal(t, "invalid_request", code(err))
+	err = scheduling.AddShow(&models.Show{MovieID: 1, ScreenID: 1, Time: at, Prices: []models.ShowPrice{
+		{Category: models.SeatPremium, Price: money.New(-1, "")},
+	}})
+	assert.Equal(t, "invalid_request", code(err))
+
+	err = scheduling.AddShow(&models.Show{MovieID: 1, ScreenID: 9, Time: at})
+	assert.Equal(t, "screen_not_found", code(err))
+
+	err = scheduling.AddShow(&models.Show{MovieID: 9, ScreenID: 1, Time: at})
+	assert.Equal(t, "movie_not_found", code(err))
+
+	// Shows are scheduled ahead of time
+	err = scheduling.AddShow(&models.Show{MovieID: 1, ScreenID: 1})
+	assert.Equal(t, "invalid_request", code(err))
+	err = scheduling.AddShow(&models.Show{MovieID: 1, ScreenID: 1, Time: clock.Add(-time.Minute)})
+	assert.Equal(t, "invalid_request", code(err))
+
+	show := models.Show{MovieID: 1, ScreenID: 1, Time: at, Price: money.New(1000, "")}
+	assert.NoError(t, scheduling.AddShow(&show))
+	assert.Equal(t, "EUR", show.Price.Currency)
+	assert.True(t, show.EndsAt.Equal(at.Add(2*time.Hour)))
+
+	_, seats, err := scheduling.AvailableSeats(show.ID)
+	assert.NoError(t, err)
+	assert.Len(t, seats, 10)
+
+	var shows int64
+	testDB.Model(&models.Show{}).Count(&shows)
+	assert.Equal(t, int64(1), shows)
+}
+
+// Test AddShow keeps the screen free for the movie and its buffers
+func TestAddShowRejectsOverlaps(t *testing.T) {
+	t.Parallel()
+	testDB, bookings, clock := newTestBookings(t)
+	scheduling := NewSchedulingService(bookings.Store, "EUR", bookings.Refunds)
+	scheduling.Now = bookings.Now
+	scheduling.AdBuffer = 15 * time.Minute
+	scheduling.CleaningBuffer = 15 * time.Minute
+
+	testDB.Create(&models.Movie{Title: "Test Movie", Duration: 90})
+	testDB.Create(&models.Theater{Name: "Test Theater", City: "Test City"})
+	testDB.Create(&models.Screen{TheaterID: 1, Name: "Screen 1", Layout: models.GridLayout("A", 2)})
+	testDB.Create(&models.Screen{TheaterID: 1, Name: "Screen 2", Layout: models.GridLayout("A", 2)})
+
+	// The first show keeps screen 1 busy from 18:00 until 20:00
+	evening := time.Date(clock.Year(), clock.Month(), clock.Day()+1, 18, 0, 0, 0, time.UTC)
+	first := models.Show{MovieID: 1, ScreenID: 1, Time: evening}
+	assert.NoError(t, scheduling.AddShow(&first))
+	assert.True(t, first.EndsAt.Equal(evening.Add(2*time.Hour)))
+
+	for _, start := range []time.Time{evening, evening.Add(-time.Hour), evening.Add(119 * time.Minute), evening.Add(-119 * time.Minute)} {
+		err := scheduling.AddShow(&models.Show{MovieID: 1, ScreenID: 1, Time: start})
+		assert.Equal(t, "show_conflict", code(err), start)
+
+		var appErr *apperror.Error
+		if assert.ErrorAs(t, err, &appErr) {
+			assert.Equal(t, first.ID, appErr.Extra["conflicting_show"].(models.Show).ID)
+		}
+	}
+
+	// Back to back is fine, and so is another screen at the same time
+	assert.NoError(t, scheduling.AddShow(&models.Show{MovieID: 1, ScreenID: 1, Time: evening.Add(2 * time.Hour)}))
+	assert.NoError(t, scheduling.AddShow(&models.Show{MovieID: 1, ScreenID: 1, Time: evening.Add(-2 * time.Hour)}))
+	assert.NoError(t, scheduling.AddShow(&models.Show{MovieID: 1, ScreenID: 2, Time: evening}))
+
+	var shows int64
+	testDB.Model(&models.Show{}).Count(&shows)
+	assert.Equal(t, int64(4), shows)
+}
+
+// Test a new movie duration moves the end of its upcoming shows, unless they
+// would then overlap
+func TestUpdateMovieRetimesShows(t *testing.T) {
+	t.Parallel()
+	testDB, _, scheduling, _, clock := newTestScheduling(t, models.GridLayout("A", 2))
+
+	at := clock.Add(24 * time.Hour)
+	first := models.Show{MovieID: 1, ScreenID: 1, Time: at}
+	second := models.Show{MovieID: 1, ScreenID: 1, Time: at.Add(3 * time.Hour)}
+	assert.NoError(t, scheduling.AddShow(&first))
+	assert.NoError(t, scheduling.AddShow(&second))
+
+	movie, err := scheduling.GetMovie(1)
+	assert.NoError(t, err)
+	movie.Duration = 150
+	_, err = scheduling.UpdateMovie(1, movie)
+	assert.NoError(t, err)
+	var show models.Show
+	testDB.First(&show, first.ID)
+	assert.True(t, show.EndsAt.Equal(at.Add(150*time.Minute)))
+
+	// Running past the next show is refused and nothing changes
+	movie.Duration = 200
+	_, err = scheduling.UpdateMovie(1, movie)
+	assert.Equal(t, "show_conflict", code(err))
+	testDB.First(&show, first.ID)
+	assert.True(t, show.EndsAt.Equal(at.Add(150*time.Minute)))
+	movie, err = scheduling.GetMovie(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 150, movie.Duration)
+}
+
+// Test schedules generate their shows, can be previewed, edited and cancelled
+func TestSchedules(t *testing.T) {
+	t.Parallel()
+	testDB, bookings, _ := newTestBookings(t)
+	scheduling := NewSchedulingService(bookings.Store, "EUR", bookings.Refunds)
+	scheduling.Now = bookings.Now
+
+	testDB.Create(&models.Movie{Title: "Test Movie", Duration: 120})
+	testDB.Create(&models.Theater{Name: "Test Theater", City: "Test City"})
+	testDB.Create(&models.Screen{TheaterID: 1, Name: "Screen 1", Layout: models.GridLayout("A", 2)})
+
+	// Mondays and Wednesdays of the week starting on the clock's Monday
+	template := func() models.ShowSchedule {
+		return models.ShowSchedule{
+			MovieID: 1, ScreenID: 1,
+			Weekdays: []string{"mon", "wed"}, Times: []string{"21:00", "18:00"},
+			StartDate: "2025-04-07", EndDate: "2025-04-13",
+			Price: money.New(1000, ""),
+		}
+	}
+	countShows := func() int64 {
+		var count int64
+		testDB.Model(&models.Show{}).Count(&count)
+		return count
+	}
+
+	schedule := template()
+	plan, err := scheduling.AddSchedule(&schedule, true)
+	assert.NoError(t, err)
+	assert.Len(t, plan.Occurrences, 4)
+	assert.Empty(t, plan.Conflicts())
+	assert.Equal(t, time.Date(2025, time.April, 7, 18, 0, 0, 0, time.UTC), plan.Occurrences[0].Show.Time)
+	assert.Equal(t, int64(0), countShows())
+
+	// A show already on Wednesday evening blocks the whole schedule
+	blocker := models.Show{MovieID: 1, ScreenID: 1, Time: time.Date(2025, time.April, 9, 19, 30, 0, 0, time.UTC), Price: money.New(1000, "")}
+	assert.NoError(t, scheduling.AddShow(&blocker))
+
+	schedule = template()
+	plan, err = scheduling.AddSchedule(&schedule, false)
+	assert.Equal(t, "schedule_conflict", code(err))
+	if assert.Len(t, plan.Conflicts(), 2) {
+		assert.Equal(t, blocker.ID, plan.Conflicts()[0].Conflict.ID)
+	}
+	assert.Equal(t, int64(1), countShows())
+
+	// Shows of the same schedule must not overlap each other either
+	schedule = template()
+	schedule.Weekdays = []string{"mon"}
+	schedule.Times = []string{"18:00", "19:00"}
+	plan, err = scheduling.AddSchedule(&schedule, false)
+	assert.Equal(t, "schedule_conflict", code(err))
+	assert.Len(t, plan.Conflicts(), 1)
+
+	schedule = template()
+	schedule.Weekdays = []string{"mon", "fri"}
+	plan, err = scheduling.AddSchedule(&schedule, false)
+	assert.NoError(t, err)
+	assert.Len(t, plan.Occurrences, 4)
+	assert.Equal(t, int64(5), countShows())
+	for _, show := range plan.Shows() {
+		if assert.NotNil(t, show.ScheduleID) {
+			assert.Equal(t, schedule.ID, *show.ScheduleID)
+		}
+	}
+	_, seats, err := scheduling.AvailableSeats(plan.Shows()[0].ID)
+	assert.NoError(t, err)
+	assert.Len(t, seats, 2)
+
+	// Editing regenerates the upcoming shows, its own old shows are no conflict
+	update := template()
+	update.Weekdays = []string{"mon", "fri"}
+	update.Times = []string{"18:00"}
+	update.Price = money.New(1200, "")
+	plan, err = scheduling.UpdateSchedule(schedule.ID, update, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, plan.Replaced)
+	assert.Len(t, plan.Occurrences, 2)
+	assert.Equal(t, int64(3), countShows())
+
+	stored, upcoming, err := scheduling.GetSchedule(schedule.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"18:00"}, stored.Times)
+	assert.Len(t, upcoming, 2)
+	assert.Equal(t, int64(1200), upcoming[0].Price.Amount)
+
+	// Sold seats keep the schedule from being changed or cancelled
+	testDB.Model(&models.Seat{}).Where("show_id = ?", upcoming[0].ID).Limit(1).Update("status", models.Booked)
+	_, err = scheduling.UpdateSchedule(schedule.ID, update, true)
+	assert.Equal(t, "schedule_has_bookings", code(err))
+	_, err = scheduling.CancelSchedule(schedule.ID)
+	assert.Equal(t, "schedule_has_bookings", code(err))
+
+	// So do live holds, but not lapsed ones
+	held := bookings.Now().Add(time.Minute)
+	testDB.Model(&models.Seat{}).Where("show_id = ?", upcoming[0].ID).
+		Updates(map[string]interface{}{"status": models.Held, "held_by": 1, "hold_expires_at": held})
+	_, err = scheduling.CancelSchedule(schedule.ID)
+	assert.Equal(t, "schedule_has_bookings", code(err))
+	lapsed := bookings.Now().Add(-time.Minute)
+	testDB.Model(&models.Seat{}).Where("show_id = ?", upcoming[0].ID).Update("hold_expires_at", lapsed)
+
+	testDB.Create(&models.ShowPrice{ShowID: upcoming[0].ID, Category: models.SeatPremium, Price: money.New(1500, "EUR")})
+	cancelled, err := scheduling.CancelSchedule(schedule.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, cancelled)
+	assert.Equal(t, int64(1), countShows())
+
+	// The cancelled shows' seats and prices go with them
+	var seatsLeft, pricesLeft int64
+	testDB.Model(&models.Seat{}).Where("show_id IN ?", []uint{upcoming[0].ID, upcoming[1].ID}).Count(&seatsLeft)
+	testDB.Model(&models.ShowPrice{}).Where("show_id IN ?", []uint{upcoming[0].ID, upcoming[1].ID}).Count(&pricesLeft)
+	assert.Zero(t, seatsLeft)
+	assert.Zero(t, pricesLeft)
+
+	_, _, err = scheduling.GetSchedule(schedule.ID)
+	assert.Equal(t, "schedule_not_found", code(err))
+}
+
+// Test a new price without a currency keeps the show's own currency, even
+// when it is not the default one
+func TestUpdateShowKeepsCurrency(t *testing.T) {
+	t.Parallel()
+	_, _, scheduling, _, clock := newTestScheduling(t, models.GridLayout("A", 2))
+	ctx := context.Background()
+
+	show := models.Show{MovieID: 1, ScreenID: 1, Time: clock.Add(48 * time.Hour), Price: money.New(1000, "EUR"),
+		Prices: []models.ShowPrice{{Category: models.SeatPremium, Price: money.New(1600, "EUR")}}}
+	assert.NoError(t, scheduling.AddShow(&show))
+
+	price := money.New(1200, "")
+	change, err := scheduling.UpdateShow(ctx, show.ID, ShowUpdate{Price: &price})
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(1200, "EUR"), change.Show.Price)
+	if assert.Len(t, change.Show.Prices, 1) {
+		assert.Equal(t, money.New(1600, "EUR"), change.Show.Prices[0].Price)
+	}
+
+	prices := []models.ShowPrice{{Category: models.SeatPremium, Price: money.New(1800, "")}}
+	change, err = scheduling.UpdateShow(ctx, show.ID, ShowUpdate{Prices: &prices})
+	assert.NoError(t, err)
+	if assert.Len(t, change.Show.Prices, 1) {
+		assert.Equal(t, money.New(1800, "EUR"), change.Show.Prices[0].Price)
+	}
+}
+
+// newTestScheduling returns a SchedulingService sharing the bookings' database
+// and clock, with a movie and a theater whose screens have the given layouts
+func newTestScheduling(t *testing.T, layouts ...models.SeatLayout) (*gorm.DB, *BookingService, *SchedulingService, *notify.Memory, *time.Time) {
+	testDB, bookings, clock := newTestBookings(t)
+	scheduling := NewSchedulingService(bookings.Store, "USD", bookings.Refunds)
+	scheduling.Now = bookings.Now
+	notices := &notify.Memory{}
+	scheduling.Notifier = notices
+
+	testDB.Create(&models.Movie{Title: "Test Movie", Duration: 120})
+	testDB.Create(&models.Theater{Name: "Test Theater", City: "Test City"})
+	for i, layout := range layouts {
+		testDB.Create(&models.Screen{TheaterID: 1, Name: "Screen " + string(rune('1'+i)), Layout: layout})
+	}
+	return testDB, bookings, scheduling, notices, clock
+}
+
+// Test CancelShow cancels and refunds its bookings and stops sales
+func TestCancelShow(t *testing.T) {
+	t.Parallel()
+	testDB, bookings, scheduling, notices, clock := newTestScheduling(t, models.GridLayout("AB", 2))
+	ctx := context.Background()
+
+	show := models.Show{MovieID: 1, ScreenID: 1, Time: clock.Add(48 * time.Hour), Price: money.New(1000, "")}
+	assert.NoError(t, scheduling.AddShow(&show))
+
+	// A paid booking, an unpaid one and a hold
+	_, err := bookings.Hold(1, show.ID, []string{"A1"})
+	assert.NoError(t, err)
+	paid, err := bookings.Book(ctx, 1, show.ID, []string{"A1"})
+	assert.NoError(t, err)
+	_, err = bookings.ConfirmPayment(ctx, 1, paid.Booking.ID)
+	assert.NoError(t, err)
+	_, err = bookings.Hold(2, show.ID, []string{"A2"})
+	assert.NoError(t, err)
+	unpaid, err := bookings.Book(ctx, 2, show.ID, []string{"A2"})
+	assert.NoError(t, err)
+	_, err = bookings.Hold(3, show.ID, []string{"B1"})
+	assert.NoError(t, err)
+
+	change, err := scheduling.CancelShow(ctx, 9, show.ID, "Projector broken")
+	assert.NoError(t, err)
+	assert.NotNil(t, change.Show.CancelledAt)
+	assert.Len(t, change.Bookings, 2)
+	if assert.Len(t, change.Refunds, 1) {
+		assert.Equal(t, paid.Booking.ID, change.Refunds[0].BookingID)
+		assert.Equal(t, money.New(1000, "USD"), change.Refunds[0].Amount)
+	}
+
+	for _, id := range []uint{paid.Booking.ID, unpaid.Booking.ID} {
+		var booking models.Booking
+		testDB.First(&booking, id)
+		assert.Equal(t, models.BookingCancelled, booking.Status)
+		assert.Equal(t, uint(9), booking.CancelledBy)
+		assert.Equal(t, "Projector broken", booking.CancelReason)
+	}
+	var refunded models.Booking
+	testDB.First(&refunded, paid.Booking.ID)
+	assert.Equal(t, models.RefundSucceeded, refunded.RefundStatus)
+
+	sent := notices.Notices()
+	if assert.Len(t, sent, 2) {
+		assert.Equal(t, notify.ShowCancelled, sent[0].Kind)
+		assert.Equal(t, uint(1), sent[0].UserID)
+		assert.Contains(t, sent[0].Message, "refunded in full")
+		assert.NotContains(t, sent[1].Message, "refunded")
+	}
+
+	// Nothing more is sold, and the screen is free again
+	_, err = bookings.Hold(4, show.ID, []string{"B2"})
+	assert.Equal(t, "show_cancelled", code(err))
+	_, err = bookings.Book(ctx, 3, show.ID, []string{"B1"})
+	assert.Equal(t, "show_cancelled", code(err))
+	_, _, err = scheduling.AvailableSeats(show.ID)
+	assert.Equal(t, "show_cancelled", code(err))
+	_, err = scheduling.CancelShow(ctx, 9, show.ID, "")
+	assert.Equal(t, "show_cancelled", code(err))
+	assert.NoError(t, scheduling.AddShow(&models.Show{MovieID: 1, ScreenID: 1, Time: show.Time}))
+
+	// Shows that already started stay as they are
+	started := models.Show{MovieID: 1, ScreenID: 1, Time: clock.Add(time.Hour)}
+	assert.NoError(t, scheduling.AddShow(&started))
+	*clock = clock.Add(2 * time.Hour)
+	_, err = scheduling.CancelShow(ctx, 9, started.ID, "")
+	assert.Equal(t, "show_started", code(err))
+}
+
+// Test UpdateShow moves bookings to the new screen's seats and flags the rest
+func TestUpdateShow(t *testing.T) {
+	t.Parallel()
+	testDB, bookings, scheduling, notices, clock := newTestScheduling(t,
+		models.GridLayout("AB", 2), models.GridLayout("A", 3))
+	ctx := context.Background()
+
+	show := models.Show{MovieID: 1, ScreenID: 1, Time: clock.Add(48 * time.Hour), Price: money.New(1000, "")}
+	assert.NoError(t, scheduling.AddShow(&show))
+
+	var ids []uint
+	for user, seat := range []string{"A1", "B2"} {
+		_, err := bookings.Hold(uint(user+1), show.ID, []string{seat})
+		assert.NoError(t, err)
+		booked, err := bookings.Book(ctx, uint(user+1), show.ID, []string{seat})
+		assert.NoError(t, err)
+		ids = append(ids, booked.Booking.ID)
+	}
+
+	// Screen 2 is taken at the new time
+	later := show.Time.Add(24 * time.Hour)
+	other := models.Show{MovieID: 1, ScreenID: 2, Time: later.Add(time.Hour)}
+	assert.NoError(t, scheduling.AddShow(&other))
+	screen := uint(2)
+	_, err := scheduling.UpdateShow(ctx, show.ID, ShowUpdate{ScreenID: &screen, Time: &later})
+	assert.Equal(t, "show_conflict", code(err))
+
+	// A new price leaves the existing bookings alone
+	price := money.New(1500, "")
+	change, err := scheduling.UpdateShow(ctx, show.ID, ShowUpdate{Price: &price})
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(1500, "USD"), change.Show.Price)
+	assert.Empty(t, notices.Notices())
+	var lineItem models.BookingLineItem
+	testDB.Where("booking_id = ?", ids[0]).First(&lineItem)
+	assert.Equal(t, int64(1000), lineItem.UnitPrice.Amount)
+
+	// Screen 2 has no row B
+	assert.NoError(t, testDB.Delete(&models.Show{}, other.ID).Error)
+	change, err = scheduling.UpdateShow(ctx, show.ID, ShowUpdate{ScreenID: &screen, Time: &later})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(2), change.Show.ScreenID)
+	assert.True(t, change.Show.Time.Equal(later))
+	assert.True(t, change.Show.EndsAt.Equal(later.Add(2*time.Hour)))
+	if assert.Len(t, change.NeedingAttention(), 1) {
+		assert.Equal(t, ids[1], change.NeedingAttention()[0].ID)
+		assert.Contains(t, change.NeedingAttention()[0].AttentionReason, "B2")
+	}
+
+	// A1 moved along with its booking, the rest of screen 2 is on sale
+	var moved models.Booking
+	testDB.Preload("Seats").First(&moved, ids[0])
+	if assert.Len(t, moved.Seats, 1) {
+		assert.Equal(t, "A", moved.Seats[0].Row)
+		assert.Equal(t, 1, moved.Seats[0].Number)
+		assert.Equal(t, models.Booked, moved.Seats[0].Status)
+		testDB.Where("booking_id = ?", ids[0]).First(&lineItem)
+		assert.Equal(t, moved.Seats[0].ID, lineItem.SeatID)
+	}
+	_, seats, err := scheduling.AvailableSeats(show.ID)
+	assert.NoError(t, err)
+	assert.Len(t, seats, 2)
+
+	var flagged models.Booking
+	testDB.First(&flagged, ids[1])
+	assert.NotEmpty(t, flagged.AttentionReason)
+
+	sent := notices.Notices()
+	if assert.Len(t, sent, 2) {
+		assert.Equal(t, notify.ShowRescheduled, sent[0].Kind)
+		assert.Contains(t, sent[1].Message, "B2")
+	}
+}
+
+// Test Listings groups upcoming shows by movie and theater and applies the filters
+func TestListings(t *testing.T) {
+	t.Parallel()
+	testDB, bookings, scheduling, _, clock := newTestScheduling(t, models.GridLayout("AB", 5))
+	ctx := context.Background()
+
+	testDB.Create(&models.Movie{Title: "Le Film", Duration: 90, Language: "French"})
+	testDB.Create(&models.Theater{Name: "Other Theater", City: "Other City"})
+	testDB.Create(&models.Screen{TheaterID: 2, Name: "Big Screen", Layout: models.GridLayout("A", 4)})
+
+	today := time.Date(2025, time.April, 7, 0, 0, 0, 0, time.UTC)
+	started := models.Show{MovieID: 1, ScreenID: 1, Time: today.Add(8 * time.Hour), EndsAt: today.Add(10 * time.Hour), Format: models.Format2D}
+	testDB.Create(&started)
+	afternoon := models.Show{MovieID: 1, ScreenID: 1, Time: today.Add(14 * time.Hour), Price: money.New(1000, "")}
+	evening := models.Show{MovieID: 1, ScreenID: 1, Time: today.Add(19 * time.Hour), Format: models.FormatIMAX, Price: money.New(1800, ""),
+		Prices: []models.ShowPrice{{Category: models.SeatPremium, Price: money.New(2500, "")}}}
+	tomorrow := models.Show{MovieID: 2, ScreenID: 2, Time: today.Add(34 * time.Hour), Format: models.Format3D, Price: money.New(1200, "")}
+	for _, show := range []*models.Show{&afternoon, &evening, &tomorrow} {
+		assert.NoError(t, scheduling.AddShow(show))
+	}
+	_, err := bookings.Hold(1, afternoon.ID, []string{"A1", "A2"})
+	assert.NoError(t, err)
+
+	showIDs := func(query ListingQuery) []uint {
+		listings, err := scheduling.Listings(query)
+		assert.NoError(t, err)
+		var ids []uint
+		for _, movie := range listings {
+			for _, theater := range movie.Theaters {
+				for _, show := range theater.Shows {
+					ids = append(ids, show.ShowID)
+				}
+			}
+		}
+		return ids
+	}
+
+	// Today by default, without the show that has started
+	listings, err := scheduling.Listings(ListingQuery{})
+	assert.NoError(t, err)
+	if assert.Len(t, listings, 1) && assert.Len(t, listings[0].Theaters, 1) {
+		assert.Equal(t, "Test Movie", listings[0].Movie.Title)
+		assert.Equal(t, "Test Theater", listings[0].Theaters[0].Name)
+		shows := listings[0].Theaters[0].Shows
+		if assert.Len(t, shows, 2) {
+			assert.Equal(t, afternoon.ID, shows[0].ShowID)
+			assert.Equal(t, int64(8), shows[0].SeatsAvailable)
+			assert.Equal(t, models.Format2D, shows[0].Format)
+			assert.Equal(t, int64(10), shows[1].SeatsAvailable)
+		}
+	}
+
+	// Seats whose hold has lapsed are on sale again, as on the seat map
+	*clock = clock.Add(bookings.HoldTTL + time.Second)
+	listings, err = scheduling.Listings(ListingQuery{})
+	assert.NoError(t, err)
+	if assert.Len(t, listings, 1) && assert.Len(t, listings[0].Theaters[0].Shows, 2) {
+		assert.Equal(t, int64(10), listings[0].Theaters[0].Shows[0].SeatsAvailable)
+	}
+
+	assert.Equal(t, []uint{afternoon.ID, evening.ID, tomorrow.ID}, showIDs(ListingQuery{From: "2025-04-07", To: "2025-04-08"}))
+	assert.Equal(t, []uint{tomorrow.ID}, showIDs(ListingQuery{To: "2025-04-08", City: "Other City"}))
+	assert.Equal(t, []uint{tomorrow.ID}, showIDs(ListingQuery{To: "2025-04-08", City: "other city"}))
+	assert.Equal(t, []uint{tomorrow.ID}, showIDs(ListingQuery{From: "2025-04-08", Language: "french"}))
+	assert.Equal(t, []uint{evening.ID}, showIDs(ListingQuery{Format: models.FormatIMAX}))
+	assert.Equal(t, []uint{evening.ID}, showIDs(ListingQuery{TimesOfDay: []string{"evening"}}))
+	assert.Equal(t, []uint{evening.ID}, showIDs(ListingQuery{MinPrice: "20"}))
+	assert.Equal(t, []uint{afternoon.ID}, showIDs(ListingQuery{MaxPrice: "11.00"}))
+	assert.Empty(t, showIDs(ListingQuery{TheaterID: 2}))
+
+	// Days and times of day are those of the time zone, 14:00 UTC is 23:00 in Tokyo
+	assert.Equal(t, []uint{afternoon.ID}, showIDs(ListingQuery{Timezone: "Asia/Tokyo", TimesOfDay: []string{"night"}}))
+
+	// Price bounds are read in each show's currency and must fit all of them.
+	// This one fits in cents but not in the fils of a dinar.
+	dinars := models.Show{MovieID: 2, ScreenID: 2, Time: today.Add(58 * time.Hour), Price: money.New(4000, "KWD")}
+	assert.NoError(t, scheduling.AddShow(&dinars))
+	assert.Equal(t, []uint{dinars.ID}, showIDs(ListingQuery{From: "2025-04-09", MinPrice: "3.5", MaxPrice: "4"}))
+	assert.Equal(t, []uint{afternoon.ID, evening.ID}, showIDs(ListingQuery{MaxPrice: "9300000000000000"}))
+	_, err = scheduling.Listings(ListingQuery{From: "2025-04-09", MaxPrice: "9300000000000000"})
+	assert.Equal(t, "invalid_query", code(err))
+
+	// Cancelled shows are not listed
+	_, err = scheduling.CancelShow(ctx, 1, evening.ID, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{afternoon.ID}, showIDs(ListingQuery{}))
+
+	for _, query := range []ListingQuery{
+		{From: "07/04/2025"},
+		{From: "2025-04-08", To: "2025-04-07"},
+		{From: "2025-04-07", To: "2025-04-21"},
+		{Timezone: "Mars/Olympus"},
+		{Format: "4D"},
+		{TimesOfDay: []string{"brunch"}},
+		{MinPrice: "cheap"},
+	} {
+		_, err := scheduling.Listings(query)
+		assert.Equal(t, "invalid_query", code(err), "%+v", query)
+	}
+}
+
+// Test SeatMap shows every position of the layout and the state of each seat
+func TestSeatMap(t *testing.T) {
+	t.Parallel()
+	layout := models.SeatLayout{Rows: []models.LayoutRow{
+		{Label: "A", Positions: []models.LayoutPosition{
+			{Kind: models.PositionSeat, Number: 1},
+			{Kind: models.PositionAisle},
+			{Kind: models.PositionSeat, Number: 2},
+			{Kind: models.PositionSeat, Number: 3},
+		}},
+		{Label: "B", Positions: []models.LayoutPosition{
+			{Kind: models.PositionGap},
+			{Kind: models.PositionSeat, Number: 1, Type: models.SeatPremium},
+		}},
+	}}
+	testDB, bookings, scheduling, _, clock := newTestScheduling(t, layout)
+	ctx := context.Background()
+
+	show := models.Show{MovieID: 1, ScreenID: 1, Time: clock.Add(24 * time.Hour), Price: money.New(1000, ""),
+		Prices: []models.ShowPrice{{Category: models.SeatPremium, Price: money.New(1600, "")}}}
+	assert.NoError(t, scheduling.AddShow(&show))
+
+	_, err := bookings.Hold(1, show.ID, []string{"A1"})
+	assert.NoError(t, err)
+	_, err = bookings.Book(ctx, 1, show.ID, []string{"A1"})
+	assert.NoError(t, err)
+	_, err = bookings.Hold(2, show.ID, []string{"A2"})
+	assert.NoError(t, err)
+	// A3 is not on sale for this show
+	assert.NoError(t, testDB.Where("show_id = ? AND row = ? AND number = ?", show.ID, "A", 3).Delete(&models.Seat{}).Error)
+
+	seatMap, err := scheduling.SeatMap(show.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Screen 1", seatMap.Screen)
+	assert.Equal(t, SeatMapCounts{Available: 1, Held: 1, Booked: 1, Blocked: 1}, seatMap.Counts)
+	if assert.Len(t, seatMap.Rows, 2) && assert.Len(t, seatMap.Rows[0].Positions, 4) && assert.Len(t, seatMap.Rows[1].Positions, 2) {
+		a := seatMap.Rows[0].Positions
+		assert.Equal(t, SeatMapBooked, a[0].State)
+		assert.Equal(t, SeatMapPosition{Kind: models.PositionAisle, X: 1}, a[1])
+		assert.Equal(t, SeatMapHeld, a[2].State)
+		assert.Equal(t, 2, a[2].X)
+		// A blocked seat is still a seat, only without a price
+		assert.Equal(t, SeatMapPosition{
+			Kind: models.PositionSeat, X: 3, Seat: "A3", Number: 3, Category: models.SeatStandard, State: SeatMapBlocked,
+		}, a[3])
+
+		b := seatMap.Rows[1].Positions
+		assert.Equal(t, models.PositionGap, b[0].Kind)
+		assert.Equal(t, "B1", b[1].Seat)
+		assert.Equal(t, 1, seatMap.Rows[1].Y)
+		assert.Equal(t, models.SeatPremium, b[1].Category)
+		assert.Equal(t, SeatMapAvailable, b[1].State)
+		assert.Equal(t, money.New(1600, "USD"), *b[1].Price)
+	}
+
+	// A lapsed hold is available again
+	*clock = clock.Add(bookings.HoldTTL + time.Minute)
+	seatMap, err = scheduling.SeatMap(show.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, SeatMapAvailable, seatMap.Rows[0].Positions[2].State)
+
+	_, err = scheduling.SeatMap(99)
+	assert.Equal(t, "show_not_found", code(err))
+}
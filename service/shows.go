@@ -0,0 +1,320 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"ETE3/apperror"
+	"ETE3/models"
+	"ETE3/money"
+	"ETE3/notify"
+	"ETE3/repository"
+)
+
+// ShowUpdate lists what changes about a show, nil fields stay as they are
+type ShowUpdate struct {
+	ScreenID *uint
+	Time     *time.Time
+	Price    *money.Money
+	Prices   *[]models.ShowPrice
+}
+
+// ShowChange is a changed or cancelled show and what happened to its bookings
+type ShowChange struct {
+	Show models.Show
+	// Bookings that held seats for the show, as they are after the change
+	Bookings []models.Booking
+	// Refunds of the bookings cancelled with the show
+	Refunds []*models.Refund
+}
+
+// NeedingAttention returns the bookings staff have to follow up on
+func (c ShowChange) NeedingAttention() []models.Booking {
+	var bookings []models.Booking
+	for _, booking := range c.Bookings {
+		if booking.AttentionReason != "" {
+			bookings = append(bookings, booking)
+		}
+	}
+	return bookings
+}
+
+// UpdateShow reschedules or reprices a show that has not started. A show moved
+// to another screen gets that screen's seats, and every booking keeps the
+// seats with the same labels. Bookings with a seat the new screen does not
+// have are flagged for staff. Customers are told about the new time or screen.
+// New prices only apply to bookings made from now on.
+func (s *SchedulingService) UpdateShow(ctx context.Context, id uint, update ShowUpdate) (ShowChange, error) {
+	if update.Time != nil {
+		if update.Time.IsZero() {
+			return ShowChange{}, apperror.Validation("invalid_request", "Invalid request data", apperror.FieldError{
+				Field: "time", Code: "required", Message: "is required",
+			})
+		}
+		if !update.Time.After(s.now()) {
+			return ShowChange{}, apperror.Validation("invalid_request", "Invalid request data", apperror.FieldError{
+				Field: "time", Code: "future", Message: "must be in the future",
+			})
+		}
+	}
+
+	var change ShowChange
+	var rescheduled bool
+	var screen models.Screen
+	err := s.Store.Transaction(func(tx repository.Store) error {
+		show, err := changeableShow(tx, id, s.now())
+		if err != nil {
+			return err
+		}
+
+		updates := make(map[string]interface{})
+		if update.Price != nil || update.Prices != nil {
+			price, prices := show.Price, show.Prices
+			if update.Price != nil {
+				price = *update.Price
+				// An amount alone keeps the show's currency
+				if price.Currency == "" {
+					price.Currency = show.Price.Currency
+				}
+			}
+			if update.Prices != nil {
+				prices = *update.Prices
+			}
+			if err := s.checkPrices(&price, prices); err != nil {
+				return err
+			}
+			updates["price_amount"] = price.Amount
+			updates["price_currency"] = price.Currency
+			if err := tx.Shows().ReplacePrices(show.ID, prices); err != nil {
+				return apperror.Internal(err, "Failed to update prices")
+			}
+		}
+
+		moved := show
+		if update.Time != nil {
+			moved.Time = update.Time.UTC()
+		}
+		if update.ScreenID != nil {
+			moved.ScreenID = *update.ScreenID
+		}
+		rescheduled = !moved.Time.Equal(show.Time) || moved.ScreenID != show.ScreenID
+
+		change.Bookings, err = tx.Bookings().ListActiveByShow(show.ID)
+		if err != nil {
+			return apperror.Internal(err, "Failed to fetch bookings")
+		}
+
+		if rescheduled {
+			movie, err := tx.Movies().Get(show.MovieID)
+			if err != nil {
+				return apperror.Internal(err, "Failed to fetch movie")
+			}
+			screen, err = tx.Theaters().LockScreen(moved.ScreenID)
+			if err != nil {
+				return notFoundOr(err, "screen_not_found", "Screen not found")
+			}
+
+			moved.EndsAt = s.showEnd(moved.Time, movie)
+			conflict, err := conflictFor(tx, moved, map[uint]bool{show.ID: true})
+			if err != nil {
+				return err
+			}
+			if conflict != nil {
+				return apperror.Conflict("show_conflict", "The screen is busy with another show at that time").
+					With("conflicting_show", *conflict)
+			}
+
+			updates["time"] = moved.Time
+			updates["ends_at"] = moved.EndsAt
+			updates["screen_id"] = moved.ScreenID
+			if moved.ScreenID != show.ScreenID {
+				if err := remapSeats(tx, show.ID, screen, change.Bookings); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(updates) > 0 {
+			if err := tx.Shows().Update(show.ID, updates); err != nil {
+				return apperror.Internal(err, "Failed to update show")
+			}
+		}
+		change.Show, err = tx.Shows().Get(show.ID)
+		if err != nil {
+			return apperror.Internal(err, "Failed to update show")
+		}
+		return nil
+	})
+	if err != nil {
+		return ShowChange{}, internalOr(err, "Failed to update show")
+	}
+
+	if rescheduled {
+		for _, booking := range change.Bookings {
+			message := fmt.Sprintf("Your show now starts at %s on %s.", change.Show.Time.Format(time.RFC1123), screen.Name)
+			if booking.AttentionReason != "" {
+				message += " " + booking.AttentionReason + ", we will be in touch about your seats."
+			}
+			s.notify(ctx, booking, notify.ShowRescheduled, message)
+		}
+	}
+	return change, nil
+}
+
+// CancelShow cancels a show that has not started along with its bookings.
+// Paid bookings are refunded in full and every customer is told.
+func (s *SchedulingService) CancelShow(ctx context.Context, staffID uint, id uint, reason string) (ShowChange, error) {
+	if reason == "" {
+		reason = "Show cancelled"
+	}
+
+	var change ShowChange
+	err := s.Store.Transaction(func(tx repository.Store) error {
+		now := s.now()
+		show, err := changeableShow(tx, id, now)
+		if err != nil {
+			return err
+		}
+
+		bookings, err := tx.Bookings().ListActiveByShow(show.ID)
+		if err != nil {
+			return apperror.Internal(err, "Failed to fetch bookings")
+		}
+		for _, booking := range bookings {
+			// The customer is not at fault, so the policy for their own cancellations does not apply
+			refund, err := s.Refunds.PrepareFull(tx.Refunds(), booking)
+			if err != nil {
+				return apperror.Internal(err, "Failed to record refund")
+			}
+			if refund != nil {
+				change.Refunds = append(change.Refunds, refund)
+			}
+
+			if err := tx.Bookings().ReleaseSeats(booking.ID); err != nil {
+				return apperror.Internal(err, "Failed to release seats")
+			}
+			if err := tx.Bookings().Update(booking.ID, map[string]interface{}{
+				"status":        models.BookingCancelled,
+				"cancelled_by":  staffID,
+				"cancelled_at":  now,
+				"cancel_reason": reason,
+			}); err != nil {
+				return apperror.Internal(err, "Failed to cancel booking")
+			}
+			booking.Status = models.BookingCancelled
+			booking.CancelledBy = staffID
+			booking.CancelledAt = &now
+			booking.CancelReason = reason
+			change.Bookings = append(change.Bookings, booking)
+		}
+
+		if err := tx.Shows().Update(show.ID, map[string]interface{}{
+			"cancelled_at":  now,
+			"cancel_reason": reason,
+		}); err != nil {
+			return apperror.Internal(err, "Failed to cancel show")
+		}
+		show.CancelledAt = &now
+		show.CancelReason = reason
+		change.Show = show
+		return nil
+	})
+	if err != nil {
+		return ShowChange{}, internalOr(err, "Failed to cancel show")
+	}
+
+	// As with customer cancellations, a refund the provider refuses is left
+	// failed for staff to follow up
+	for _, refund := range change.Refunds {
+		if err := s.Refunds.Issue(ctx, s.Store, refund); err != nil {
+			log.Printf("❌ Error recording refund for booking %d: %v", refund.BookingID, err)
+		}
+	}
+	refunded := make(map[uint]bool, len(change.Refunds))
+	for _, refund := range change.Refunds {
+		refunded[refund.BookingID] = true
+	}
+	for _, booking := range change.Bookings {
+		message := fmt.Sprintf("Your show at %s has been cancelled: %s.", change.Show.Time.Format(time.RFC1123), reason)
+		if refunded[booking.ID] {
+			message += " You will be refunded in full."
+		}
+		s.notify(ctx, booking, notify.ShowCancelled, message)
+	}
+	return change, nil
+}
+
+// changeableShow returns a show that can still be changed or cancelled
+func changeableShow(tx repository.Store, id uint, now time.Time) (models.Show, error) {
+	show, err := openShow(tx, id)
+	if err != nil {
+		return models.Show{}, err
+	}
+	if !show.Time.After(now) {
+		return models.Show{}, apperror.Conflict("show_started", "Show has already started")
+	}
+	return show, nil
+}
+
+// remapSeats gives a show the seats of its new screen. Each booking gets the
+// seats with the labels it had, and is flagged if some of them are missing.
+// Holds are dropped, their seats are on sale again on the new screen.
+func remapSeats(tx repository.Store, showID uint, screen models.Screen, bookings []models.Booking) error {
+	if err := tx.Seats().DeleteByShow(showID); err != nil {
+		return apperror.Internal(err, "Failed to replace seats")
+	}
+	seats := screen.Layout.SeatsForShow(showID)
+	if err := tx.Seats().CreateMany(seats); err != nil {
+		return apperror.Internal(err, "Failed to create seats")
+	}
+
+	byLabel := make(map[string]models.Seat, len(seats))
+	for _, seat := range seats {
+		byLabel[fmt.Sprintf("%s%d", seat.Row, seat.Number)] = seat
+	}
+
+	for i, booking := range bookings {
+		var missing []string
+		for _, old := range booking.Seats {
+			label := fmt.Sprintf("%s%d", old.Row, old.Number)
+			seat, ok := byLabel[label]
+			if !ok {
+				missing = append(missing, label)
+				continue
+			}
+			delete(byLabel, label)
+
+			if err := tx.Seats().Update(seat, map[string]interface{}{"status": old.Status}); err != nil {
+				return apperror.Internal(err, "Failed to move seats")
+			}
+			if err := tx.Bookings().MoveSeat(booking.ID, old.ID, seat.ID); err != nil {
+				return apperror.Internal(err, "Failed to move seats")
+			}
+		}
+
+		if len(missing) > 0 {
+			reason := fmt.Sprintf("Seats %s are not on the new screen", strings.Join(missing, ", "))
+			if err := tx.Bookings().Update(booking.ID, map[string]interface{}{"attention_reason": reason}); err != nil {
+				return apperror.Internal(err, "Failed to flag booking")
+			}
+			bookings[i].AttentionReason = reason
+		}
+	}
+	return nil
+}
+
+// notify tells the booking's customer about a change, failures are only logged
+func (s *SchedulingService) notify(ctx context.Context, booking models.Booking, kind string, message string) {
+	err := s.notifier().Notify(ctx, notify.Notice{
+		UserID:    booking.UserID,
+		BookingID: booking.ID,
+		Kind:      kind,
+		Message:   message,
+	})
+	if err != nil {
+		log.Printf("❌ Error notifying user %d about booking %d: %v", booking.UserID, booking.ID, err)
+	}
+}